@@ -22,6 +22,25 @@ type Alleler struct {
 	ReFile   string       // ex. "genome.counts_GATC.txt"
 	Paf      PAFFile      // The PAF data
 	ReCounts RECountsFile // The RE data
+	// Synteny mode
+	ContigBedFile string         // ex. "contigs.bed"
+	RefBedFile    string         // ex. "reference.bed"
+	AnchorsFile   string         // ex. "contigs.reference.last"
+	MinBlockSize  int            // Minimum number of anchors in a synteny block
+	MaxGap        int            // Maximum distance (in genes) to chain two anchors
+	MinCscore     float64        // Minimum C-score to keep an anchor
+	Blocks        []SyntenyBlock // Synteny blocks between contigs and reference
+	// K-mer mode
+	Fastafile      string      // ex. "genome.fasta"
+	K              int         // K-mer size
+	Scale          int         // Keep 1 in Scale k-mers in the FracMinHash sketch
+	MaxOccurrence  int         // Ignore hashes found in more contigs than this
+	MinHashes      int         // Minimum number of hashes in the shorter contig
+	MinLengthRatio float64     // Minimum length ratio of the shorter vs the longer contig
+	MinContainment float64     // Minimum containment of the shorter contig in the longer
+	Alleles        []AlleleRow // Allele groups inferred from synteny or k-mers
+	// Output file
+	OutAllelesFile string
 }
 
 // Tag represents the additional info in the 12+ columns in the PAF
@@ -146,6 +165,16 @@ func (r *Alleler) extractAllelicPairs() {
 
 // Run kicks off the Alleler
 func (r *Alleler) Run() {
+	if r.AnchorsFile != "" {
+		r.runSynteny()
+		log.Notice("Success")
+		return
+	}
+	if r.Fastafile != "" {
+		r.runKmer()
+		log.Notice("Success")
+		return
+	}
 	r.Paf = PAFFile{PafFile: r.PafFile}
 	r.Paf.ParseRecords()
 	r.ReCounts = RECountsFile{Filename: r.ReFile}
@@ -9,7 +9,13 @@
 package allhic_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/tanghaibao/allhic"
@@ -43,3 +49,104 @@ func TestParsePafFile(t *testing.T) {
 		t.Fatalf("The first record is expected to have length %d, got %d", expectedLength, gotLength)
 	}
 }
+
+func TestSyntenyAlleles(t *testing.T) {
+	alleler := allhic.Alleler{
+		ContigBedFile:  filepath.Join("tests", "simulation", "testtigs.bed"),
+		RefBedFile:     filepath.Join("tests", "simulation", "testchr.bed"),
+		AnchorsFile:    filepath.Join("tests", "simulation", "testtigs.testchr.last"),
+		MinBlockSize:   allhic.MinBlockSize,
+		MaxGap:         allhic.MaxGap,
+		MinCscore:      allhic.MinCscore,
+		OutAllelesFile: filepath.Join(t.TempDir(), "alleles.table"),
+	}
+	alleler.Run()
+	expectedNumBlocks := 79 // One block per contig with at least MinBlockSize genes
+	if len(alleler.Blocks) != expectedNumBlocks {
+		t.Fatalf("Expected %d synteny blocks, got %d", expectedNumBlocks, len(alleler.Blocks))
+	}
+	// The simulated contigs are from a single haplotype so none are allelic
+	if len(alleler.Alleles) != 0 {
+		t.Fatalf("Expected no allele groups, got %d", len(alleler.Alleles))
+	}
+}
+
+func TestSketchSequenceIsCanonical(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	seq := make([]byte, 5000)
+	for i := range seq {
+		seq[i] = "ACGT"[rng.Intn(4)]
+	}
+	complement := map[byte]byte{'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A'}
+	revcomp := make([]byte, len(seq))
+	for i, c := range seq {
+		revcomp[len(seq)-1-i] = complement[c]
+	}
+	maxHash := uint64(math.MaxUint64) / 10
+	got := allhic.SketchSequence(seq, allhic.KmerSize, maxHash)
+	expected := allhic.SketchSequence(revcomp, allhic.KmerSize, maxHash)
+	if len(got) == 0 {
+		t.Fatal("Expected a non-empty sketch")
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Sketches of the sequence and its reverse complement differ (%d vs %d hashes)",
+			len(got), len(expected))
+	}
+}
+
+func TestKmerAlleles(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	randomSeq := func(n int) []byte {
+		seq := make([]byte, n)
+		for i := range seq {
+			seq[i] = "ACGT"[rng.Intn(4)]
+		}
+		return seq
+	}
+	// tig1 and tig2 are near-identical, tig3 is unrelated and all three carry
+	// the same repeat, which is removed from the sketches
+	repeat := randomSeq(10000)
+	tig1 := append(randomSeq(10000), repeat...)
+	tig2 := append([]byte{}, tig1[:19000]...)
+	for i := 0; i < len(tig2); i += 200 {
+		tig2[i] = map[byte]byte{'A': 'C', 'C': 'G', 'G': 'T', 'T': 'A'}[tig2[i]]
+	}
+	tig3 := append(randomSeq(10000), repeat...)
+	fasta := ""
+	for i, seq := range [][]byte{tig1, tig2, tig3} {
+		fasta += fmt.Sprintf(">tig%d\n%s\n", i+1, seq)
+	}
+	dir := t.TempDir()
+	fastaFile := filepath.Join(dir, "genome.fasta")
+	if err := ioutil.WriteFile(fastaFile, []byte(fasta), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	alleler := allhic.Alleler{Fastafile: fastaFile, K: allhic.KmerSize, Scale: 10,
+		MaxOccurrence: 2, MinHashes: allhic.MinHashes, MinLengthRatio: allhic.MinLengthRatio,
+		MinContainment: allhic.MinContainment}
+	alleler.Run()
+	expected := []allhic.AlleleRow{
+		{Chrom: allhic.KmerAllelesChrom, Pos: 1, Contigs: []string{"tig1", "tig2"}},
+	}
+	if !reflect.DeepEqual(alleler.Alleles, expected) {
+		t.Fatalf("Expected allele groups %v, got %v", expected, alleler.Alleles)
+	}
+
+	// The containment only counts the hashes outside the repeat
+	data, err := ioutil.ReadFile(filepath.Join(dir, "genome.alleles.pairs.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b string
+	var la, lb, shared int
+	var containment, jaccard float64
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(rows) != 2 {
+		t.Fatalf("Expected 1 allelic pair, got %d", len(rows)-1)
+	}
+	fmt.Sscan(rows[1], &a, &b, &la, &lb, &shared, &containment, &jaccard)
+	if containment < .8 {
+		t.Errorf("Expected containment of tig2 in tig1 > 0.8, got %.4f", containment)
+	}
+}
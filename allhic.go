@@ -72,6 +72,11 @@ also prepares for the latter steps of ALLHiC.
 	extractCmd.Flags().StringVarP(&RE, "RE", "", DefaultRE, "Restriction site pattern, use comma to separate multiple patterns (N is considered as [ACGT]), e.g. 'GATCGATC,GANTGATC,GANTANTC,GATCANTC'")
 	extractCmd.Flags().IntVarP(&minLinks, "minLinks", "", MinLinks, "Minimum number of links for contig pair")
 
+	var synteny, kmer bool
+	var minBlockSize, maxGap int
+	var minCscore float64
+	var kmerSize, scale, maxOccurrence, minHashes int
+	var minLengthRatio, minContainment float64
 	allelesCmd := &cobra.Command{
 		Use:   "alleles genome.paf genome.counts_RE.txt",
 		Short: "Build alleles.table for `prune`",
@@ -84,16 +89,72 @@ $ minimap2 -DP -k19 -w19 -m200 -t32 genome.fasta genome.fasta > genome.paf
 
 The PAF file contains all self-alignments, which is the basis for classification.
 ALLHiC generates "alleles.table", which can then be used for later steps.
+
+Alternatively, with --synteny, the allele table is built from gene synteny to
+a closely-related reference genome:
+
+$ allhic alleles --synteny contigs.bed reference.bed contigs.reference.last
+
+The bed files contain the gene locations on the contigs and on the reference,
+and the anchors file is the tabular BLAST or LAST output of the contig genes
+against the reference genes. Contigs that are syntenic to the same reference
+gene are considered allelic.
+
+With --kmer, the allele table is built without alignments. Each contig is
+sketched with FracMinHash, and contigs are grouped when the shorter contig
+shares most of its k-mers with the longer contig:
+
+$ allhic alleles --kmer genome.fasta
+
+The groups have no reference locus, and are numbered along the pseudo-
+chromosome "kmer" in the table. The k-mer similarity scores between contig
+pairs are also written.
 `,
-		Args: cobra.ExactArgs(2),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if synteny {
+				return cobra.ExactArgs(3)(cmd, args)
+			}
+			if kmer {
+				return cobra.ExactArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
+			if synteny {
+				p := Alleler{ContigBedFile: args[0], RefBedFile: args[1], AnchorsFile: args[2],
+					MinBlockSize: minBlockSize, MaxGap: maxGap, MinCscore: minCscore}
+				p.Run()
+				return
+			}
+			if kmer {
+				p := Alleler{Fastafile: args[0], K: kmerSize, Scale: scale,
+					MaxOccurrence: maxOccurrence, MinHashes: minHashes,
+					MinLengthRatio: minLengthRatio, MinContainment: minContainment}
+				p.Run()
+				return
+			}
 			pafFile := args[0]
 			reFile := args[1]
 			p := Alleler{PafFile: pafFile, ReFile: reFile}
 			p.Run()
 		},
 	}
+	allelesCmd.Flags().BoolVarP(&synteny, "synteny", "", false, "Build alleles.table from gene synteny (contigs.bed reference.bed anchors)")
+	allelesCmd.Flags().IntVarP(&minBlockSize, "minBlockSize", "", MinBlockSize, "Minimum number of anchors in a synteny block")
+	allelesCmd.Flags().IntVarP(&maxGap, "maxGap", "", MaxGap, "Maximum distance (in number of genes) to chain two anchors")
+	allelesCmd.Flags().Float64VarP(&minCscore, "minCscore", "", MinCscore, "Minimum C-score to keep an anchor")
+	allelesCmd.Flags().BoolVarP(&kmer, "kmer", "", false, "Build alleles.table from k-mer sketches of the contigs (genome.fasta)")
+	allelesCmd.Flags().IntVarP(&kmerSize, "k", "", KmerSize, "K-mer size (at most 32)")
+	allelesCmd.Flags().IntVarP(&scale, "scale", "", SketchScale, "Keep 1 in every scale k-mers in the sketch")
+	allelesCmd.Flags().IntVarP(&maxOccurrence, "maxOccurrence", "", MaxOccurrence, "Ignore k-mers found in more contigs than this")
+	allelesCmd.Flags().IntVarP(&minHashes, "minHashes", "", MinHashes, "Minimum number of sketched k-mers in the shorter contig")
+	allelesCmd.Flags().Float64VarP(&minLengthRatio, "minLengthRatio", "", MinLengthRatio, "Minimum length ratio of the shorter contig vs the longer contig")
+	allelesCmd.Flags().Float64VarP(&minContainment, "minContainment", "", MinContainment, "Minimum fraction of k-mers in the shorter contig shared with the longer contig")
 
+	var normalize, pruneREFile, pruneClmFile string
+	var minRatio float64
+	var pruneMinLinks, topN int
+	var pruneReport bool
 	pruneCmd := &cobra.Command{
 		Use:   "prune alleles.table pairs.txt",
 		Short: "Prune allelic, cross-allelic and weak links",
@@ -118,19 +179,57 @@ looks like:
 
 tig00030660,PRIMARY -> tig00003333,HAPLOTIG
                     -> tig00038686,HAPLOTIG
+
+Longer contigs tend to have more links, so the single-best edge is chosen on
+link scores normalized by --normalize: "re" (RE sites in both contigs),
+"expected" (observed / expected links) or "length" (contig lengths, requires
+--counts). The default "none" uses the raw links as in earlier versions, which
+favor the longer contigs. Pairs without the values to normalize with, such as
+contigs without RE sites, are scored 0. The normalization used is recorded in
+the labels of pruned pairs.
+
+Finally, weak links can be removed, which helps with noisy low-coverage
+libraries. A link is weak if it has fewer than --minLinks links, if the ratio of
+observed vs expected links is below --minRatio, or if it is not among the --topN
+strongest (normalized) partners of either contig. These are labeled as weak(...).
+
+Pruning only changes "pairs.txt" for "partition". To let "optimize" ignore the
+same links, use --clm to write "genome.prune.clm" without the pruned contig pairs,
+which can then be used in place of the clmfile in "optimize".
+
+Use --report to write "pairs.prune.report.json" that lists, for every allele
+group, the allelic edges, the bipartite matchings against the other groups with
+the score matrices and the runner-up solutions (near-ties are flagged as
+ambiguous), the final label of each edge, and the contigs left without any links.
 `,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			allelesFile := args[0]
 			pairsFile := args[1]
-			p := Pruner{AllelesFile: allelesFile, PairsFile: pairsFile}
+			p := Pruner{AllelesFile: allelesFile, PairsFile: pairsFile,
+				REFile: pruneREFile, Normalize: normalize,
+				MinRatio: minRatio, MinLinks: pruneMinLinks, TopN: topN,
+				ClmFile: pruneClmFile, Report: pruneReport}
 			p.Run()
 		},
 	}
+	pruneCmd.Flags().StringVarP(&normalize, "normalize", "", NormalizeNone, "Normalize link scores by none|re|expected|length")
+	pruneCmd.Flags().StringVarP(&pruneREFile, "counts", "", "", "RE counts file (counts_RE.txt), required to normalize by length")
+	pruneCmd.Flags().Float64VarP(&minRatio, "minRatio", "", 0, "Prune links with observed / expected links below this ratio")
+	pruneCmd.Flags().IntVarP(&pruneMinLinks, "minLinks", "", 0, "Prune links with fewer observed links than this")
+	pruneCmd.Flags().IntVarP(&topN, "topN", "", 0, "Prune links not in the top N partners of either contig (0 to disable)")
+	pruneCmd.Flags().StringVarP(&pruneClmFile, "clm", "", "", "Clmfile to write a pruned copy (.prune.clm) for optimize")
+	pruneCmd.Flags().BoolVarP(&pruneReport, "report", "", false, "Write pruning decisions per allele group to a JSON report")
 
 	var minREs, maxLinkDensity, nonInformativeRatio int
+	var method string
+	var resolution float64
+	var minK, maxK int
+	var partitionAllelesFile, constraintsFile, homologPairsFile, anchorsFile, fromClusters, groupSizes, cisFile, exportGraph string
+	var ploidy, genomeSize int
+	var allelicPenalty float64
 	partitionCmd := &cobra.Command{
-		Use:   "partition counts_RE.txt pairs.txt k",
+		Use:   "partition counts_RE.txt pairs.txt [k|auto]",
 		Short: "Separate contigs into k groups",
 		Long: `
 Partition function:
@@ -139,26 +238,200 @@ separate all the contigs into separate clusters. As with all clustering
 algorithm, there is an optimization goal here. The LACHESIS algorithm is
 a hierarchical clustering algorithm using average links. The two input files
 can be generated with the "extract" sub-command.
+
+Alternatively, use --method louvain|leiden|newman to find the clusters by
+community detection on the same normalized link matrix. The number of clusters
+is then determined by maximizing modularity, and can be tuned with --resolution
+(larger values give more, smaller clusters); k is only used to name the output
+files. The default --method ahc is the LACHESIS algorithm.
+
+If the number of groups is unknown, use "auto" in place of k. The hierarchy is
+built once and cut at each k between --minK and --maxK. Each solution is scored
+by modularity, ratio of intra- vs inter-cluster links, and balance of the
+cluster lengths, and the k with the best modularity x balance is selected. The
+intra/inter ratio is reported only, and not used in the selection. The k that
+the hierarchy cannot be cut into (nClusters differs from k) are listed but never
+selected. The scores are written to "clusters.auto.txt".
+
+Contigs from the same allele group can be prevented from clustering together
+with --alleles, using the same "alleles.table" as in "prune". Merges between
+clusters with allelic contigs are rejected, or with --allelicPenalty below 1,
+the merge score is multiplied by (1 - penalty) for each allelic pair.
+
+External evidence (BAC, genetic or optical maps) can be added with --constraints,
+a tab-separated file with "tigA tigB must|cannot [weight]" on each line. Without
+weight (or weight >= 1), must-links seed the clusters and cannot-links reject
+merges. Weights below 1 multiply the merge score by (1 + weight) for must-links
+and (1 - weight) for cannot-links. Constraints not satisfied are logged.
+
+For autopolyploids, use --ploidy with k as the number of chromosomes. The
+contigs are first clustered into k homologous groups using the unpruned pairs
+given by --homologPairs, then each group is split into --ploidy haplotypes using
+the (pruned) pairs.txt. The clusters are labeled as Chr3.hap2, and so are the
+counts files, e.g. "counts_RE.Chr3.hap2.txt".
+
+With the average-linkage clustering, the full sequence of merges is written to
+"merges.txt" along with the linkage and the number of clusters after each merge,
+and the hierarchy is written in Newick format to "dendrogram.nwk". The merges go
+on past k until no clusters are linked, so the hierarchy can be cut at any level.
+
+With --reference-anchors, the contigs with confident unique alignments to a
+related reference (PAF, e.g. from "minimap2 -x asm10 ref.fasta contigs.fasta")
+seed the clusters by the reference chromosomes, so that only the unanchored
+contigs are clustered by Hi-C links. The clusters are named after the
+reference chromosomes.
+
+If the chromosome sizes are roughly known, use --groupSizes (comma-separated
+sizes in bp) or --genomeSize (divided evenly into k groups) to balance the
+clusters. Clusters over 1.5x the largest expected group are bisected using the
+links within the cluster, and clusters under 0.25x the smallest expected group
+are merged into the cluster they link to most.
+
+All contigs are classified as INFORMATIVE, SHORT, REPETITIVE, ISOLATED (no links
+to other contigs) or ORGANELLE (dense cis links and nearly no trans links, likely
+organelle or contaminant) in "contigs.class.txt", along with the RE counts, link
+factor, cis/trans ratio and the cluster. The cis and trans links are read from
+"sample.cis.txt" written by extract, or from --cis.
+
+Use --export-graph to write the contig link graph, with the normalized links as
+edge weights and the contig length, RE counts, cluster and skip reason as node
+attributes. The format follows the extension (.graphml, .gexf or .dot), which
+can be opened in Cytoscape, Gephi or Graphviz.
+
+To use a clusters.txt that is edited by hand, e.g. moving contigs between groups
+or splitting a group, use --from-clusters without k. The clusters are checked
+for unknown or duplicated contigs, and the per-group counts files and the
+quality report are regenerated, without clustering again. The edited file is
+never overwritten, even if it is the "clusters.txt" next to the pairs file.
+
+The quality of the partition is written to "clusters.quality.txt", with the
+links within and between clusters, and to "contigs.quality.txt", where each
+contig is compared against its strongest competing cluster. Contigs with
+negative silhouette are more strongly linked to another cluster.
 `,
-		Args: cobra.ExactArgs(3),
+		Args: cobra.RangeArgs(2, 3),
 		Run: func(cmd *cobra.Command, args []string) {
 			contigsfile := args[0]
 			pairsFile := args[1]
-			k, _ := strconv.Atoi(args[2])
+			if len(args) < 3 && fromClusters == "" {
+				log.Fatalf("k is required unless --from-clusters is given")
+			}
+			autoK := len(args) == 3 && args[2] == "auto"
+			k := 0
+			if len(args) == 3 && !autoK {
+				k, _ = strconv.Atoi(args[2])
+			}
 			p := Partitioner{Contigsfile: contigsfile, PairsFile: pairsFile, K: k,
+				AutoK: autoK, MinK: minK, MaxK: maxK,
+				AllelesFile: partitionAllelesFile, AllelicPenalty: allelicPenalty,
+				ConstraintsFile: constraintsFile, AnchorsFile: anchorsFile,
+				FromClusters: fromClusters, GenomeSize: genomeSize, CisFile: cisFile,
+				ExportGraph: exportGraph,
+				Ploidy:      ploidy, HomologPairsFile: homologPairsFile,
 				MinREs: minREs, MaxLinkDensity: maxLinkDensity,
-				NonInformativeRatio: nonInformativeRatio}
+				NonInformativeRatio: nonInformativeRatio,
+				Method:              method, Resolution: resolution}
+			if groupSizes != "" {
+				p.GroupSizes = parseGroupSizes(groupSizes)
+			}
 			p.Run()
 		},
 	}
 	partitionCmd.Flags().IntVarP(&minREs, "minREs", "", MinREs, "Minimum number of RE sites in a contig to be clustered (CLUSTER_MIN_RE_SITES in LACHESIS)")
 	partitionCmd.Flags().IntVarP(&maxLinkDensity, "maxLinkDensity", "", MaxLinkDensity, "Density threshold before marking contig as repetive (CLUSTER_MAX_LINK_DENSITY in LACHESIS)")
 	partitionCmd.Flags().IntVarP(&nonInformativeRatio, "nonInformativeRatio", "", NonInformativeRatio, "cutoff for recovering skipped contigs back into the clusters (CLUSTER_NONINFORMATIVE_RATIO in LACHESIS)")
+	partitionCmd.Flags().StringVarP(&method, "method", "", MethodAHC, "Clustering method ahc|louvain|leiden|newman")
+	partitionCmd.Flags().Float64VarP(&resolution, "resolution", "", Resolution, "Resolution in modularity for louvain|leiden|newman")
+	partitionCmd.Flags().IntVarP(&minK, "minK", "", MinK, "Smallest k to try in partition auto")
+	partitionCmd.Flags().IntVarP(&maxK, "maxK", "", MaxK, "Largest k to try in partition auto")
+	partitionCmd.Flags().StringVarP(&partitionAllelesFile, "alleles", "", "", "Alleles table (as in prune) to keep allelic contigs apart")
+	partitionCmd.Flags().Float64VarP(&allelicPenalty, "allelicPenalty", "", AllelicPenalty, "Penalty on merges between allelic contigs, 1 to reject")
+	partitionCmd.Flags().StringVarP(&constraintsFile, "constraints", "", "", "Must-link and cannot-link constraints (tigA tigB must|cannot [weight])")
+	partitionCmd.Flags().StringVarP(&anchorsFile, "reference-anchors", "", "", "PAF of the contigs against a related reference to seed the clusters")
+	partitionCmd.Flags().StringVarP(&groupSizes, "groupSizes", "", "", "Expected group sizes in bp, comma-separated, to split or merge clusters")
+	partitionCmd.Flags().IntVarP(&genomeSize, "genomeSize", "", 0, "Expected genome size in bp, divided evenly into k groups, to split or merge clusters")
+	partitionCmd.Flags().StringVarP(&cisFile, "cis", "", "", "Cis and trans links per contig from extract, default to sample.cis.txt if found")
+	partitionCmd.Flags().StringVarP(&exportGraph, "export-graph", "", "", "Write the contig link graph to .graphml, .gexf or .dot")
+	partitionCmd.Flags().StringVarP(&fromClusters, "from-clusters", "", "", "Take the clusters from clusters.txt (e.g. edited by hand) instead of clustering")
+	partitionCmd.Flags().IntVarP(&ploidy, "ploidy", "", 1, "Split each homologous group into this many haplotypes")
+	partitionCmd.Flags().StringVarP(&homologPairsFile, "homologPairs", "", "", "Unpruned pairs.txt to find the homologous groups, required with --ploidy")
+
+	var binMethod, coverageFile, binCisFile string
+	var binResolution float64
+	var minBinSize, binMinREs int
+	binCmd := &cobra.Command{
+		Use:   "bin counts_RE.txt pairs.txt [contigs.fasta]",
+		Short: "Bin metagenomic contigs into genomes",
+		Long: `
+Bin function:
+Group the contigs of a metagenome into genome bins using the Hi-C links, when
+the number of genomes is unknown and their abundances vary widely. The links are
+normalized by the RE sites and by the coverage of both contigs, then the bins
+are found by community detection (--method louvain|leiden), which determines the
+number of bins.
 
-	var skipGA, resume bool
+The coverage is read from --coverage (contig and depth, with a header line), or
+estimated from the Hi-C links per bp in "sample.cis.txt" written by extract.
+Communities shorter than --minBinSize, such as plasmids, are attached to the
+host bin they link to the most. Unlinked contigs with dense cis links, likely
+complete circular replicons, are kept as their own bins.
+
+The bins are written to "bins.txt" with the role of each contig (CORE, ATTACHED,
+REPLICON, AMBIGUOUS, UNLINKED or SHORT), and to "bin001.fasta" etc. if the
+contigs FASTA is given.
+`,
+		Args: cobra.RangeArgs(2, 3),
+		Run: func(cmd *cobra.Command, args []string) {
+			p := Binner{Contigsfile: args[0], PairsFile: args[1],
+				CoverageFile: coverageFile, CisFile: binCisFile,
+				MinREs: binMinREs, Method: binMethod, Resolution: binResolution,
+				MinBinSize: minBinSize}
+			if len(args) == 3 {
+				p.Fastafile = args[2]
+			}
+			p.Run()
+		},
+	}
+	binCmd.Flags().StringVarP(&binMethod, "method", "", MethodLeiden, "Community detection method louvain|leiden")
+	binCmd.Flags().Float64VarP(&binResolution, "resolution", "", Resolution, "Resolution in modularity, larger values give more bins")
+	binCmd.Flags().IntVarP(&minBinSize, "minBinSize", "", MinBinSize, "Minimum total length of a bin")
+	binCmd.Flags().IntVarP(&binMinREs, "minREs", "", MinREs, "Minimum number of RE sites in a contig to be binned")
+	binCmd.Flags().StringVarP(&coverageFile, "coverage", "", "", "Contig depth from read mapping, tab-separated contig and depth after a header line")
+	binCmd.Flags().StringVarP(&binCisFile, "cis", "", "", "Cis and trans links per contig from extract, default to sample.cis.txt if found")
+
+	var rescueRatio float64
+	var rescueMinLinks int
+	rescueCmd := &cobra.Command{
+		Use:   "rescue counts_RE.txt pairs.txt clusters.txt",
+		Short: "Assign unplaced contigs to groups",
+		Long: `
+Rescue function:
+Assign the contigs that are not in "clusters.txt" to the groups, such as the
+contigs skipped as SHORT or REPETITIVE in partition that failed the
+NonInformativeRatio. An unassigned contig is placed in the group with the
+largest average linkage if it has at least --minLinks Hi-C links to the group,
+and the average linkage is at least --ratio times that of the second best group.
+
+The per-group RE counts files, e.g. "counts_RE.12g1.txt", are updated so that
+optimize can be rerun on the groups. The decision on each unassigned contig is
+written to "clusters.rescue.txt", with status RESCUED, FEW_LINKS, LOW_RATIO or
+NO_LINKS.
+`,
+		Args: cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			p := Rescuer{Contigsfile: args[0], PairsFile: args[1], ClustersFile: args[2],
+				Ratio: rescueRatio, MinLinks: rescueMinLinks}
+			p.Run()
+		},
+	}
+	rescueCmd.Flags().Float64VarP(&rescueRatio, "ratio", "", NonInformativeRatio, "Min ratio of the average linkage to the best group vs the second best")
+	rescueCmd.Flags().IntVarP(&rescueMinLinks, "minLinks", "", RescueMinLinks, "Min number of Hi-C links to the best group")
+
+	var skipGA, resume, joint bool
 	var seed int64
 	var npop, ngen int
-	var mutpb float64
+	var mutpb, crosspb float64
+	var crossover, optimizeMethod string
 	optimizeCmd := &cobra.Command{
 		Use:   "optimize counts_RE.txt clmfile",
 		Short: "Order-and-orient tigs in a group",
@@ -171,6 +444,23 @@ as generated by "partition" sub-command, with the group_number matching the
 order appearing in "clusters.txt". Typically, if there are k clusters, we
 can start k separate "optimize" commands for parallelism (for example,
 on a cluster).
+
+The GA mutates the tours by default. Crossover between two tours can be
+turned on with --crossover, using one of the order-preserving operators:
+pmx (Partially Mapped Crossover), ox (Ordered Crossover) or erx (Edge
+Recombination Crossover), applied with probability --crossprob.
+
+Other than GA (--method ga), the ordering can be searched by simulated
+annealing (sa) with the inversion and insertion moves, by the deterministic
+2-opt and Or-opt local search (localsearch), or by GA followed by the local
+search to polish the GA solution (hybrid). All methods log the intermediate
+tours to the same tour file.
+
+By default GA only changes the ordering, and the orientations are fixed
+afterwards. With --joint, GA changes the orientations along with the
+ordering, with mutations that flip single contigs or invert blocks, and
+scores the tours with the orientation-aware link distances. --joint only
+works with --method ga, as the local search does not change the orientations.
 `,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
@@ -178,16 +468,21 @@ on a cluster).
 			clmfile := args[1]
 			p := Optimizer{REfile: refile, Clmfile: clmfile,
 				RunGA: !skipGA, Resume: resume,
-				Seed: seed, NPop: npop, NGen: ngen, MutProb: mutpb}
+				Seed: seed, NPop: npop, NGen: ngen, MutProb: mutpb,
+				Crossover: crossover, CrossProb: crosspb, Method: optimizeMethod, Joint: joint}
 			p.Run()
 		},
 	}
-	optimizeCmd.Flags().BoolVarP(&skipGA, "skipGA", "", false, "Skip GA step")
+	optimizeCmd.Flags().BoolVarP(&skipGA, "skipGA", "", false, "Skip GA step, only with --method ga")
 	optimizeCmd.Flags().BoolVarP(&resume, "resume", "", false, "Resume from existing tour file")
 	optimizeCmd.Flags().Int64VarP(&seed, "seed", "", Seed, "Random seed")
 	optimizeCmd.Flags().IntVarP(&npop, "npop", "", Npop, "Population size")
 	optimizeCmd.Flags().IntVarP(&ngen, "ngen", "", Ngen, "Number of generations for convergence")
 	optimizeCmd.Flags().Float64VarP(&mutpb, "mutapb", "", MutaProb, "Mutation prob in GA")
+	optimizeCmd.Flags().StringVarP(&crossover, "crossover", "", CrossoverNone, "Crossover operator in GA none|pmx|ox|erx")
+	optimizeCmd.Flags().Float64VarP(&crosspb, "crossprob", "", CrossProb, "Crossover prob in GA")
+	optimizeCmd.Flags().StringVarP(&optimizeMethod, "method", "", MethodGA, "Ordering method ga|sa|localsearch|hybrid")
+	optimizeCmd.Flags().BoolVarP(&joint, "joint", "", false, "GA on ordering and orientations jointly, only with --method ga")
 
 	buildCmd := &cobra.Command{
 		Use:   "build tourfile1 tourfile2 ... contigs.fasta asm.chr.fasta",
@@ -287,7 +582,8 @@ A convenience driver function. Chain the following steps sequentially.
 				optimizer := Optimizer{REfile: refile,
 					Clmfile: extractor.OutClmfile,
 					RunGA:   !skipGA, Resume: resume,
-					Seed: seed, NPop: npop, NGen: ngen, MutProb: mutpb}
+					Seed: seed, NPop: npop, NGen: ngen, MutProb: mutpb,
+					Crossover: crossover, CrossProb: crosspb, Method: optimizeMethod, Joint: joint}
 				optimizer.Run()
 				tourfiles = append(tourfiles, optimizer.OutTourFile)
 			}
@@ -309,12 +605,16 @@ A convenience driver function. Chain the following steps sequentially.
 	pipelineCmd.Flags().IntVarP(&maxLinkDensity, "maxLinkDensity", "", MaxLinkDensity, "Density threshold before marking contig as repetive (CLUSTER_MAX_LINK_DENSITY in LACHESIS)")
 	pipelineCmd.Flags().IntVarP(&nonInformativeRatio, "nonInformativeRatio", "", NonInformativeRatio, "cutoff for recovering skipped contigs back into the clusters (CLUSTER_NONINFORMATIVE_RATIO in LACHESIS)")
 
-	pipelineCmd.Flags().BoolVarP(&skipGA, "skipGA", "", false, "Skip GA step")
+	pipelineCmd.Flags().BoolVarP(&skipGA, "skipGA", "", false, "Skip GA step, only with --method ga")
 	pipelineCmd.Flags().BoolVarP(&resume, "resume", "", false, "Resume from existing tour file")
 	pipelineCmd.Flags().Int64VarP(&seed, "seed", "", Seed, "Random seed")
 	pipelineCmd.Flags().IntVarP(&npop, "npop", "", Npop, "Population size")
 	pipelineCmd.Flags().IntVarP(&ngen, "ngen", "", Ngen, "Number of generations for convergence")
 	pipelineCmd.Flags().Float64VarP(&mutpb, "mutapb", "", MutaProb, "Mutation prob in GA")
+	pipelineCmd.Flags().StringVarP(&crossover, "crossover", "", CrossoverNone, "Crossover operator in GA none|pmx|ox|erx")
+	pipelineCmd.Flags().Float64VarP(&crosspb, "crossprob", "", CrossProb, "Crossover prob in GA")
+	pipelineCmd.Flags().StringVarP(&optimizeMethod, "method", "", MethodGA, "Ordering method ga|sa|localsearch|hybrid")
+	pipelineCmd.Flags().BoolVarP(&joint, "joint", "", false, "GA on ordering and orientations jointly, only with --method ga")
 
-	rootCmd.AddCommand(extractCmd, allelesCmd, pruneCmd, partitionCmd, optimizeCmd, buildCmd, plotCmd, assessCmd, pipelineCmd)
+	rootCmd.AddCommand(extractCmd, allelesCmd, pruneCmd, partitionCmd, rescueCmd, binCmd, optimizeCmd, buildCmd, plotCmd, assessCmd, pipelineCmd)
 }
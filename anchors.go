@@ -0,0 +1,148 @@
+/*
+ *  anchors.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"fmt"
+	"sort"
+)
+
+// referenceHits sums the aligned bases of one contig on each reference chromosome
+type referenceHits struct {
+	aligned map[string]int
+	total   int
+}
+
+// readReferenceAnchors assigns the contigs to the reference chromosomes, based
+// on the alignments of the contigs against a related reference, e.g.
+// $ minimap2 -x asm10 reference.fasta contigs.fasta > contigs_vs_ref.paf
+// A contig is anchored if at least AnchorMinCoverage of its length is aligned
+// with mapping quality of AnchorMinMapQ or above, and at least AnchorMinPurity
+// of the aligned bases are on the same chromosome.
+func (r *Partitioner) readReferenceAnchors() {
+	paf := PAFFile{PafFile: r.AnchorsFile}
+	paf.ParseRecords()
+
+	hits := map[int]*referenceHits{}
+	for _, rec := range paf.Records {
+		idx, ok := r.contigToIdx[rec.Query]
+		if !ok || rec.MappingQuality < AnchorMinMapQ {
+			continue
+		}
+		h, ok := hits[idx]
+		if !ok {
+			h = &referenceHits{aligned: map[string]int{}}
+			hits[idx] = h
+		}
+		h.aligned[rec.Target] += rec.QueryEnd - rec.QueryStart
+		h.total += rec.QueryEnd - rec.QueryStart
+	}
+
+	r.anchors = map[int]string{}
+	nAmbiguous, nLowCoverage := 0, 0
+	for idx, h := range hits {
+		best, bestAligned := "", 0
+		for chr, aligned := range h.aligned {
+			if aligned > bestAligned || (aligned == bestAligned && chr < best) {
+				best, bestAligned = chr, aligned
+			}
+		}
+		if float64(h.total) < AnchorMinCoverage*float64(r.contigs[idx].length) {
+			nLowCoverage++
+			continue
+		}
+		if float64(bestAligned) < AnchorMinPurity*float64(h.total) {
+			nAmbiguous++
+			continue
+		}
+		r.anchors[idx] = best
+	}
+	log.Noticef("Anchored %d contigs to the reference (%d with low coverage, %d on multiple chromosomes)",
+		len(r.anchors), nLowCoverage, nAmbiguous)
+	r.seedAnchors()
+}
+
+// seedAnchors converts the anchors into constraints. The contigs anchored to
+// the same chromosome are must-linked to the longest informative one, which
+// seeds the cluster in the hierarchical clustering. The seeds of different
+// chromosomes are cannot-linked, so they are never merged.
+func (r *Partitioner) seedAnchors() {
+	chrContigs := map[string][]int{}
+	for idx, chr := range r.anchors {
+		chrContigs[chr] = append(chrContigs[chr], idx)
+	}
+	r.referenceChrs = make([]string, 0, len(chrContigs))
+	for chr := range chrContigs {
+		r.referenceChrs = append(r.referenceChrs, chr)
+	}
+	sort.Strings(r.referenceChrs)
+
+	seeds := []int{}
+	for _, chr := range r.referenceChrs {
+		ids := chrContigs[chr]
+		sort.Ints(ids)
+		seed := -1
+		for _, idx := range ids {
+			if r.contigs[idx].skip {
+				continue
+			}
+			if seed == -1 || r.contigs[idx].length > r.contigs[seed].length {
+				seed = idx
+			}
+		}
+		if seed == -1 {
+			log.Warningf("No informative contigs anchored to %s", chr)
+			continue
+		}
+		for _, idx := range ids {
+			if idx != seed {
+				r.addConstraint(Constraint{a: seed, b: idx, must: true, weight: 1, anchored: true})
+			}
+		}
+		seeds = append(seeds, seed)
+	}
+	for i := 0; i < len(seeds); i++ {
+		for j := i + 1; j < len(seeds); j++ {
+			r.addConstraint(Constraint{a: seeds[i], b: seeds[j], weight: 1, anchored: true})
+		}
+	}
+	if len(seeds) != r.K && !r.AutoK {
+		log.Warningf("%d reference chromosomes seeded but k = %d", len(seeds), r.K)
+	}
+	log.Noticef("Seeded %d clusters from %d reference chromosomes", len(seeds), len(r.referenceChrs))
+}
+
+// labelAnchoredClusters names the clusters after the reference chromosomes
+// that their anchored contigs are on. Clusters without anchored contigs, or
+// with contigs anchored to several chromosomes, keep the default names.
+func (r *Partitioner) labelAnchoredClusters() {
+	r.labels = make([]string, len(r.clusters))
+	used := map[string]bool{}
+	nLabeled := 0
+	for j := 0; j < len(r.clusters); j++ {
+		r.labels[j] = fmt.Sprintf("%dg%d", r.K, j+1)
+		chrs := map[string]bool{}
+		for _, idx := range r.clusters[j] {
+			if chr, ok := r.anchors[idx]; ok {
+				chrs[chr] = true
+			}
+		}
+		if len(chrs) != 1 {
+			continue
+		}
+		for chr := range chrs {
+			if !used[chr] {
+				r.labels[j] = chr
+				used[chr] = true
+				nLabeled++
+			}
+		}
+	}
+	log.Noticef("%d of %d clusters named after the reference chromosomes", nLabeled, len(r.clusters))
+}
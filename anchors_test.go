@@ -0,0 +1,47 @@
+/*
+ *  anchors_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeedAnchors(t *testing.T) {
+	// Contigs 1 and 3 have the most links but are anchored to different
+	// chromosomes, contig 2 is skipped and chr3 has no informative contigs
+	links := map[[2]int]int64{{1, 3}: 100, {0, 1}: 1, {3, 5}: 1}
+	r := newTestPartitioner(links, 6)
+	r.contigs[1].length = 200000
+	r.contigs[2].skip = true
+	r.contigs[4].skip = true
+	r.anchors = map[int]string{0: "chr1", 1: "chr1", 2: "chr1", 3: "chr2", 4: "chr3"}
+	r.seedAnchors()
+
+	if expected := []string{"chr1", "chr2", "chr3"}; !reflect.DeepEqual(r.referenceChrs, expected) {
+		t.Errorf("Expected reference chromosomes %v, got %v", expected, r.referenceChrs)
+	}
+	// The longest informative contig seeds chr1, and the seeds are cannot-linked
+	expected := []Constraint{
+		{a: 1, b: 0, must: true, weight: 1, anchored: true},
+		{a: 1, b: 2, must: true, weight: 1, anchored: true},
+		{a: 1, b: 3, weight: 1, anchored: true},
+	}
+	if !reflect.DeepEqual(r.constraints, expected) {
+		t.Fatalf("Expected constraints %+v, got %+v", expected, r.constraints)
+	}
+
+	h := r.hierarchicalClustering(0)
+	if !sameCluster(h, 0, 1) {
+		t.Errorf("Expected contigs 0 and 1 on chr1 in the same cluster, got %v", h.clusterID)
+	}
+	if sameCluster(h, 1, 3) {
+		t.Errorf("Expected the seeds 1 and 3 in different clusters, got %v", h.clusterID)
+	}
+}
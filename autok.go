@@ -0,0 +1,157 @@
+/*
+ *  autok.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+)
+
+// KCandidate stores the clustering and the scores for one choice of k
+type KCandidate struct {
+	K               int
+	nClusters       int
+	Modularity      float64
+	IntraInterRatio float64
+	LengthBalance   float64
+	Score           float64
+	clusters        Clusters
+}
+
+// SelectK builds the complete average-linkage hierarchy once, then cuts it for
+// each k between MinK and MaxK. Each solution is scored and the one with the
+// highest score is kept. The score is the modularity times the length balance,
+// so that solutions with a few giant clusters are not favored. The ratio of
+// intra- vs inter-cluster links is reported only. The cuts that cannot reach k
+// clusters, e.g. when the remaining clusters are not linked, are listed but
+// never selected.
+func (r *Partitioner) SelectK() {
+	if r.Method != "" && r.Method != MethodAHC {
+		log.Fatalf("Automatic k is only supported with --method %s", MethodAHC)
+	}
+	if r.MinK < 1 || r.MaxK < r.MinK {
+		log.Fatalf("Invalid range of k: %d-%d", r.MinK, r.MaxK)
+	}
+	h := r.hierarchicalClustering(0)
+	r.history = h.history
+	g, nodes := r.makeCommunityGraph()
+
+	candidates := []*KCandidate{}
+	var best *KCandidate
+	for k := r.MinK; k <= r.MaxK; k++ {
+		r.setClusters(cutHierarchy(r.contigs, h.history, k))
+		c := r.scoreClusters(g, nodes)
+		c.K = k
+		candidates = append(candidates, c)
+		if c.nClusters != k {
+			log.Noticef("Skipped k = %d since the hierarchy is cut into %d clusters", k, c.nClusters)
+			continue
+		}
+		if best == nil || c.Score > best.Score {
+			best = c
+		}
+	}
+
+	if best == nil {
+		log.Fatalf("The hierarchy cannot be cut into %d-%d clusters", r.MinK, r.MaxK)
+	}
+	r.K = best.K
+	r.clusters = best.clusters
+	log.Noticef("Selected k = %d (modularity = %.4f, intra/inter = %.4g, balance = %.4f)",
+		best.K, best.Modularity, best.IntraInterRatio, best.LengthBalance)
+	r.writeKCandidates(candidates, best)
+}
+
+// scoreClusters computes the modularity, the ratio of intra- vs inter-cluster
+// links and the balance of the cluster lengths for the current clusters. The
+// length balance is 1 / (1 + coefficient of variation) of the cluster lengths.
+func (r *Partitioner) scoreClusters(g *communityGraph, nodes []int) *KCandidate {
+	contigToCluster := map[int]int{}
+	lengths := make([]float64, 0, len(r.clusters))
+	for cID, cl := range r.clusters {
+		length := 0
+		for _, id := range cl {
+			contigToCluster[id] = cID
+			length += r.contigs[id].length
+		}
+		lengths = append(lengths, float64(length))
+	}
+
+	// Contigs left out of the clusters are each in their own community
+	membership := make([]int, len(nodes))
+	for a, i := range nodes {
+		membership[a] = len(r.contigs) + i
+		if cID, ok := contigToCluster[i]; ok {
+			membership[a] = cID
+		}
+	}
+	intra, inter := 0.0, 0.0
+	for a, edges := range g.adj {
+		ca, aok := contigToCluster[nodes[a]]
+		for _, e := range edges {
+			cb, bok := contigToCluster[nodes[e.to]]
+			if !aok || !bok {
+				continue
+			}
+			if ca == cb {
+				intra += e.w
+			} else {
+				inter += e.w
+			}
+		}
+	}
+	ratio := math.Inf(1)
+	if inter > 0 {
+		ratio = intra / inter
+	}
+
+	balance := 0.0
+	if len(lengths) > 0 {
+		mean := sumf(lengths) / float64(len(lengths))
+		variance := 0.0
+		for _, length := range lengths {
+			variance += (length - mean) * (length - mean)
+		}
+		cv := math.Sqrt(variance/float64(len(lengths))) / mean
+		balance = 1 / (1 + cv)
+	}
+
+	Q := modularity(g, membership, 1)
+	return &KCandidate{
+		nClusters:       len(r.clusters),
+		Modularity:      Q,
+		IntraInterRatio: ratio,
+		LengthBalance:   balance,
+		Score:           Q * balance,
+		clusters:        r.clusters,
+	}
+}
+
+// writeKCandidates writes the scores of all choices of k next to clusters.txt
+func (r *Partitioner) writeKCandidates(candidates []*KCandidate, best *KCandidate) {
+	outfile := RemoveExt(RemoveExt(r.PairsFile)) + ".clusters.auto.txt"
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	fmt.Fprintf(w, KCandidatesHeader)
+	for _, c := range candidates {
+		chosen := ""
+		if c == best {
+			chosen = "*"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%.5f\t%.4g\t%.4f\t%.5f\t%s\n",
+			c.K, c.nClusters, c.Modularity, c.IntraInterRatio, c.LengthBalance, c.Score, chosen)
+	}
+	w.Flush()
+	log.Noticef("Scores of %d choices of k written to `%s`", len(candidates), outfile)
+}
@@ -0,0 +1,215 @@
+/*
+ *  balance.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseGroupSizes parses the comma-separated expected group sizes in bp,
+// e.g. 120000000,95000000,80000000
+func parseGroupSizes(s string) []int {
+	sizes := []int{}
+	for _, word := range strings.Split(s, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(word))
+		if err != nil || size <= 0 {
+			log.Fatalf("Malformed group size: %s, expecting positive integer", word)
+		}
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	return sizes
+}
+
+// expectedGroupSizes returns the expected group sizes, either given directly
+// or as the genome size divided evenly into k groups
+func (r *Partitioner) expectedGroupSizes() []int {
+	if len(r.GroupSizes) > 0 {
+		return r.GroupSizes
+	}
+	sizes := make([]int, r.K)
+	for i := range sizes {
+		sizes[i] = r.GenomeSize / r.K
+	}
+	return sizes
+}
+
+// clusterLength returns the total length of the contigs in a cluster
+func (r *Partitioner) clusterLength(cl []int) int {
+	length := 0
+	for _, id := range cl {
+		length += r.contigs[id].length
+	}
+	return length
+}
+
+// clusterLinkage returns the average linkage between two groups of contigs,
+// with links averaged in both directions
+func (r *Partitioner) clusterLinkage(a, b []int) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inB := map[int]bool{}
+	for _, j := range b {
+		inB[j] = true
+	}
+	total := 0.0
+	for _, i := range a {
+		for j, w := range r.matrix[i] {
+			if inB[j] {
+				total += float64(w+r.matrix[j][i]) / 2
+			}
+		}
+	}
+	return total / float64(len(a)) / float64(len(b))
+}
+
+// hasCannotLink returns true if there is a hard cannot-link between a contig
+// in a and a contig in b
+func (r *Partitioner) hasCannotLink(a, b []int) bool {
+	inB := map[int]bool{}
+	for _, j := range b {
+		inB[j] = true
+	}
+	for _, i := range a {
+		for _, c := range r.contigConstraints[i] {
+			if !c.must && c.isHard() && (inB[c.a] || inB[c.b]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// balanceClusters compares the clusters against the expected group sizes.
+// Clusters longer than BalanceMaxRatio times the largest expected group are
+// bisected recursively, and clusters shorter than BalanceMinRatio times the
+// smallest expected group are merged into their best-linked neighbor.
+func (r *Partitioner) balanceClusters() {
+	expected := r.expectedGroupSizes()
+	if len(expected) == 0 || expected[0] <= 0 {
+		log.Fatalf("Expected group sizes must be positive")
+	}
+	maxLength := int(BalanceMaxRatio * float64(expected[0]))
+	minLength := int(BalanceMinRatio * float64(expected[len(expected)-1]))
+	log.Noticef("Balance %d clusters against %d expected groups (split above %d bp, merge below %d bp)",
+		len(r.clusters), len(expected), maxLength, minLength)
+
+	clusters := [][]int{}
+	for j := 0; j < len(r.clusters); j++ {
+		clusters = append(clusters, r.bisectCluster(r.clusters[j], maxLength)...)
+	}
+	clusters = r.mergeTinyClusters(clusters, minLength)
+
+	r.clusters = Clusters{}
+	for j, cl := range clusters {
+		r.clusters[j] = cl
+	}
+	r.sortClusters()
+	if len(r.clusters) != len(expected) {
+		log.Warningf("%d clusters after balancing, expecting %d groups", len(r.clusters), len(expected))
+	}
+}
+
+// bisectCluster splits a cluster in two using the hierarchical clustering on
+// the intra-cluster submatrix, and then splits the halves again if they are
+// still longer than maxLength
+func (r *Partitioner) bisectCluster(cl []int, maxLength int) [][]int {
+	length := r.clusterLength(cl)
+	if length <= maxLength || len(cl) < 2 {
+		return [][]int{cl}
+	}
+	sub := r.subPartitioner(cl, 2)
+	sub.Method = MethodAHC
+	sub.Cluster()
+	halves := subClusters(sub, cl)
+	if len(halves) != 2 {
+		log.Warningf("Cannot split cluster of %d contigs (%d bp) into two", len(cl), length)
+		return [][]int{cl}
+	}
+
+	// Contigs left out of the two halves go with the half they link to more
+	inHalves := map[int]bool{}
+	for _, half := range halves {
+		for _, id := range half {
+			inHalves[id] = true
+		}
+	}
+	for _, id := range cl {
+		if inHalves[id] {
+			continue
+		}
+		best := 0
+		if r.clusterLinkage([]int{id}, halves[1]) > r.clusterLinkage([]int{id}, halves[0]) {
+			best = 1
+		}
+		halves[best] = append(halves[best], id)
+	}
+	for _, half := range halves {
+		sort.Ints(half)
+	}
+
+	log.Noticef("Split cluster of %d contigs (%d bp) into %d (%d bp) + %d (%d bp): intra-linkage %.1f / %.1f, inter-linkage %.1f",
+		len(cl), length, len(halves[0]), r.clusterLength(halves[0]), len(halves[1]), r.clusterLength(halves[1]),
+		r.clusterLinkage(halves[0], halves[0]), r.clusterLinkage(halves[1], halves[1]),
+		r.clusterLinkage(halves[0], halves[1]))
+	clusters := [][]int{}
+	for _, half := range halves {
+		clusters = append(clusters, r.bisectCluster(half, maxLength)...)
+	}
+	return clusters
+}
+
+// mergeTinyClusters merges the clusters shorter than minLength, starting from
+// the shortest, into the cluster with the largest average linkage that it
+// has no hard cannot-links to
+func (r *Partitioner) mergeTinyClusters(clusters [][]int, minLength int) [][]int {
+	unlinked := map[int]bool{} // Tiny clusters kept as is, keyed by their first contig
+	for {
+		tiny := -1
+		for j, cl := range clusters {
+			length := r.clusterLength(cl)
+			if unlinked[cl[0]] {
+				continue
+			}
+			if length < minLength && (tiny == -1 || length < r.clusterLength(clusters[tiny])) {
+				tiny = j
+			}
+		}
+		if tiny == -1 || len(clusters) < 2 {
+			return clusters
+		}
+
+		best, secondLinkage, bestLinkage := -1, 0.0, 0.0
+		for j, cl := range clusters {
+			if j == tiny || r.hasCannotLink(clusters[tiny], cl) {
+				continue
+			}
+			linkage := r.clusterLinkage(clusters[tiny], cl)
+			if linkage > bestLinkage {
+				best, secondLinkage, bestLinkage = j, bestLinkage, linkage
+			} else if linkage > secondLinkage {
+				secondLinkage = linkage
+			}
+		}
+		if best == -1 {
+			log.Warningf("Cluster of %d contigs (%d bp) is not linked to other clusters, kept as is",
+				len(clusters[tiny]), r.clusterLength(clusters[tiny]))
+			unlinked[clusters[tiny][0]] = true
+			continue
+		}
+		log.Noticef("Merge cluster of %d contigs (%d bp) into cluster of %d contigs (%d bp): linkage %.1f, runner-up %.1f",
+			len(clusters[tiny]), r.clusterLength(clusters[tiny]), len(clusters[best]), r.clusterLength(clusters[best]),
+			bestLinkage, secondLinkage)
+		clusters[best] = append(clusters[best], clusters[tiny]...)
+		clusters = append(clusters[:tiny], clusters[tiny+1:]...)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ *  balance_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// sortedClusters returns the clusters as sorted strings, to be compared
+// regardless of the order
+func sortedClusters(clusters Clusters) []string {
+	s := []string{}
+	for _, cl := range clusters {
+		ids := append([]int{}, cl...)
+		sort.Ints(ids)
+		s = append(s, fmt.Sprint(ids))
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestBalanceClusters(t *testing.T) {
+	// Contigs 0-2 and 3-5 are two chromosomes of 300 kb in the same cluster,
+	// contigs 6 and 7 are tiny clusters, 6 is linked to both chromosomes and
+	// 7 is not linked
+	links := map[[2]int]int64{{0, 1}: 50, {0, 2}: 50, {1, 2}: 50, {3, 4}: 50, {3, 5}: 50, {4, 5}: 50,
+		{0, 3}: 2, {3, 6}: 10, {0, 6}: 5}
+	tests := []struct {
+		name     string
+		cannot   bool
+		expected []string
+	}{
+		{"split and merge", false, []string{"[0 1 2]", "[3 4 5 6]", "[7]"}},
+		// The cannot-link keeps 6 away from its best-linked cluster
+		{"cannot-link", true, []string{"[0 1 2 6]", "[3 4 5]", "[7]"}},
+	}
+	for _, tt := range tests {
+		r := newTestPartitioner(links, 8)
+		r.contigs[6].length = 10000
+		r.contigs[7].length = 10000
+		if tt.cannot {
+			r.addConstraint(Constraint{a: 3, b: 6, weight: 1})
+		}
+		r.clusters = Clusters{0: {0, 1, 2, 3, 4, 5}, 1: {6}, 2: {7}}
+		r.GroupSizes = []int{300000, 300000}
+		r.balanceClusters()
+		if got := sortedClusters(r.clusters); fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: expected clusters %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
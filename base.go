@@ -47,6 +47,42 @@ const (
 	// MinLinkDist is the minimum link distance we care about
 	MinLinkDist = 1 << 11
 
+	/* alleles */
+	// MinBlockSize is the minimum number of anchors in a synteny block
+	MinBlockSize = 3
+	// MaxGap is the maximum distance (in number of genes) to chain two anchors
+	MaxGap = 20
+	// MinCscore is the minimum ratio of the anchor score against the best score of either gene
+	MinCscore = .7
+	// KmerSize is the k-mer size used to sketch the contigs
+	KmerSize = 21
+	// SketchScale keeps 1 in every SketchScale k-mers in the sketch
+	SketchScale = 200
+	// MaxOccurrence is the max number of contigs a hash can be found in, otherwise considered repetitive
+	MaxOccurrence = 20
+	// MinHashes is the minimum number of hashes in the shorter contig to report a pair
+	MinHashes = 10
+	// MinLengthRatio is the minimum length ratio between the shorter and longer contig in a pair
+	MinLengthRatio = .05
+	// MinContainment is the minimum fraction of shared hashes in the shorter contig
+	MinContainment = .5
+	// KmerAllelesChrom is the pseudo-chromosome of the allele groups from k-mers
+	KmerAllelesChrom = "kmer"
+
+	/* prune */
+	// NormalizeNone uses the raw number of links as the score
+	NormalizeNone = "none"
+	// NormalizeRE normalizes the number of links by the RE sites in both contigs
+	NormalizeRE = "re"
+	// NormalizeExpected normalizes the number of links by the expected number of links
+	NormalizeExpected = "expected"
+	// NormalizeLength normalizes the number of links by the lengths of both contigs
+	NormalizeLength = "length"
+	// QuantizeScale is the largest integer weight used in the bipartite matching
+	QuantizeScale = 1000000000
+	// TieRatio is the max relative difference between the best and runner-up matchings to be ambiguous
+	TieRatio = .05
+
 	/* extract */
 	// DefaultRE is the default restriction site used
 	DefaultRE = "GATC"
@@ -64,6 +100,50 @@ const (
 	// LinkDist specifies to maximum size of the links going over a certain position
 	LinkDist = int64(1000000)
 
+	/* partition */
+	// MethodAHC is the LACHESIS average-linkage hierarchical clustering
+	MethodAHC = "ahc"
+	// MethodLouvain is the Louvain community detection
+	MethodLouvain = "louvain"
+	// MethodLeiden is the Louvain community detection with Leiden refinement
+	MethodLeiden = "leiden"
+	// MethodNewman is the Newman spectral modularity partitioning
+	MethodNewman = "newman"
+	// Resolution is the resolution parameter in modularity, larger values give more communities
+	Resolution = 1.0
+	// AllelicPenalty is the penalty on merges between clusters with allelic contigs, 1 to reject
+	AllelicPenalty = 1.0
+	// MinK is the smallest k tried in partition auto
+	MinK = 2
+	// MaxK is the largest k tried in partition auto
+	MaxK = 50
+	// OrganelleMinCoverage is the min density of cis links relative to the median contig to be organelle
+	OrganelleMinCoverage = 5.0
+	// OrganelleMaxTransFraction is the max fraction of trans links in all links to be organelle
+	OrganelleMaxTransFraction = .01
+	// BalanceMaxRatio is the max cluster length relative to the largest expected group before splitting
+	BalanceMaxRatio = 1.5
+	// BalanceMinRatio is the min cluster length relative to the smallest expected group before merging
+	BalanceMinRatio = .25
+	// AnchorMinMapQ is the minimum mapping quality of the alignments to the reference
+	AnchorMinMapQ = 30
+	// AnchorMinCoverage is the minimum fraction of the contig aligned to the reference to be anchored
+	AnchorMinCoverage = .5
+	// AnchorMinPurity is the minimum fraction of the aligned bases on the same reference chromosome
+	AnchorMinPurity = .9
+
+	/* bin */
+	// MinBinSize is the minimum total length of a bin
+	MinBinSize = 200000
+	// BinHostRatio is the min ratio of links to the best bin vs the second best to attach a small contig
+	BinHostRatio = 2.0
+	// BinMinCisLinks is the minimum number of cis links for an unlinked contig to be a standalone replicon
+	BinMinCisLinks = 100
+
+	/* rescue */
+	// RescueMinLinks is the minimum number of links from an unassigned contig to the group
+	RescueMinLinks = 10
+
 	/* optimize */
 	// Seed is the random seed
 	Seed = 42
@@ -73,6 +153,40 @@ const (
 	Ngen = 5000
 	// MutaProb is the mutation probability in GA
 	MutaProb = 0.2
+	// CrossProb is the crossover probability in GA
+	CrossProb = 0.2
+	// CrossoverNone disables crossover in GA, offsprings are only mutated
+	CrossoverNone = "none"
+	// CrossoverPMX is the Partially Mapped Crossover
+	CrossoverPMX = "pmx"
+	// CrossoverOX is the Ordered Crossover
+	CrossoverOX = "ox"
+	// CrossoverERX is the Edge Recombination Crossover
+	CrossoverERX = "erx"
+	// MethodGA orders the contigs with the Genetic Algorithm
+	MethodGA = "ga"
+	// MethodSA orders the contigs with simulated annealing
+	MethodSA = "sa"
+	// MethodLocalSearch orders the contigs with 2-opt and Or-opt local search
+	MethodLocalSearch = "localsearch"
+	// MethodHybrid runs GA followed by the local search
+	MethodHybrid = "hybrid"
+	// SASampleMoves is the number of random moves sampled to set the initial temperature
+	SASampleMoves = 100
+	// SAInitialAcceptance is the probability to accept an average uphill move at the initial temperature
+	SAInitialAcceptance = .5
+	// SACoolingRate is the ratio between two consecutive temperatures
+	SACoolingRate = .95
+	// SAMovesPerTig is the number of moves per contig at each temperature
+	SAMovesPerTig = 20
+	// SAMinTempRatio stops SA when the temperature drops below this fraction of the initial temperature
+	SAMinTempRatio = 1e-3
+	// SASnapshotLevels is the number of temperatures between two snapshots in the tour file
+	SASnapshotLevels = 10
+	// OrOptMaxSegment is the maximum number of consecutive contigs moved in Or-opt
+	OrOptMaxSegment = 3
+	// MinImprovement is the minimum relative score change to accept a move in the local search
+	MinImprovement = 1e-12
 
 	// *** The following parameters are modeled after LACHESIS ***
 	// MinREs is the minimum number of RE sites in a contig to be clustered (CLUSTER_MIN_RE_SITES)
@@ -94,6 +208,33 @@ const (
 	// DistributionHeader is the first line in the distribution.txt file
 	DistributionHeader = "#Bin\tBinStart\tBinSize\tNumLinks\tTotalSize\tLinkDensity\n"
 
+	// KCandidatesHeader is the first line in the clusters.auto.txt file
+	KCandidatesHeader = "#K\tnClusters\tModularity\tIntraInterRatio\tLengthBalance\tScore\tSelected\n"
+
+	// MergeHistoryHeader is the first line in the merges.txt file
+	MergeHistoryHeader = "#Merge\tClusterA\tClusterB\tNewCluster\tSize\tLinkage\tnClusters\n"
+
+	// CisTransHeader is the first line in the cis.txt file
+	CisTransHeader = "#Contig\tCisLinks\tTransLinks\n"
+
+	// ContigClassHeader is the first line in the contigs.class.txt file
+	ContigClassHeader = "#Contig\tRECounts\tLength\tLinkFactor\tCisLinks\tTransLinks\tCisTransRatio\tCategory\tCluster\n"
+
+	// BinsHeader is the first line in the bins.txt file
+	BinsHeader = "#Bin\tContig\tLength\tRECounts\tCoverage\tRole\n"
+
+	// ClusterQualityHeader is the first line in the clusters.quality.txt file
+	ClusterQualityHeader = "#Cluster\tnContigs\tLength\tIntraLinks\tInterLinks\tIntraFraction\tSilhouette\tnShort\tnRepetitive\tnRecovered\n"
+
+	// ContigQualityHeader is the first line in the contigs.quality.txt file
+	ContigQualityHeader = "#Contig\tCluster\tOwnLinkage\tCompetitor\tCompetitorLinkage\tSilhouette\tStatus\n"
+
+	// RescueHeader is the first line in the clusters.rescue.txt file
+	RescueHeader = "#Contig\tLength\tGroup\tLinks\tAvgLinkage\tCompetitor\tCompetitorLinkage\tStatus\n"
+
+	// AllelicPairsHeader is the first line in the allelic pairs file
+	AllelicPairsHeader = "#Contig1\tContig2\tLength1\tLength2\tSharedHashes\tContainment\tJaccard\n"
+
 	// PostProbHeader is the first line in the postprob file
 	PostProbHeader = "#SeqID\tStart\tEnd\tContig\tPostProb\n"
 )
@@ -149,6 +290,16 @@ func IsNewerFile(a, b string) bool {
 	return am.Sub(bm) > 0
 }
 
+// IsSameFile checks if file a and file b are the same file on disk
+func IsSameFile(a, b string) bool {
+	af, aerr := os.Stat(a)
+	bf, berr := os.Stat(b)
+	if aerr != nil || berr != nil {
+		return false
+	}
+	return os.SameFile(af, bf)
+}
+
 // Round makes a round number
 func Round(input float64) float64 {
 	if input < 0 {
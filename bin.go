@@ -0,0 +1,304 @@
+/*
+ *  bin.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/shenwei356/xopen"
+)
+
+// Binner groups the contigs of a metagenome into genome bins, where the number
+// of bins is unknown and the genomes have very different abundances
+type Binner struct {
+	Contigsfile  string
+	PairsFile    string
+	Fastafile    string // Optional, write the bins in FASTA
+	CisFile      string // Optional, cis and trans links from extract as coverage
+	CoverageFile string // Optional, contig depth from read mapping
+	// Parameters
+	MinREs     int
+	Method     string // louvain or leiden
+	Resolution float64
+	MinBinSize int // Min total length of a bin
+	// Output files
+	OutBinsfile string
+	p           *Partitioner
+	coverage    []float64
+	bins        [][]int
+	roles       map[int]string
+}
+
+// Run is the main function body of bin
+func (r *Binner) Run() {
+	r.p = &Partitioner{Contigsfile: r.Contigsfile, PairsFile: r.PairsFile,
+		MinREs: r.MinREs, Method: r.Method, Resolution: r.Resolution, CisFile: r.CisFile}
+	p := r.p
+	p.readRE()
+	p.skipContigsWithFewREs()
+	r.readCoverage()
+	p.makeMatrix()
+	r.normalizeCoverage()
+	r.findBins()
+	r.writeBins()
+	if r.Fastafile != "" {
+		r.writeBinsFasta()
+	}
+	log.Notice("Success")
+}
+
+// readCoverage reads the contig depth from the coverage file, or estimates the
+// depth from the number of Hi-C links per bp in the cis.txt from extract. The
+// coverage file must start with a header line, which is skipped.
+// #Contig    Depth
+func (r *Binner) readCoverage() {
+	p := r.p
+	r.coverage = make([]float64, len(p.contigs))
+	if r.CoverageFile != "" {
+		nFound := 0
+		for _, rec := range ReadCSVLines(r.CoverageFile) {
+			if len(rec) < 2 {
+				log.Fatalf("Expecting contig and depth in `%s`, got %v", r.CoverageFile, rec)
+			}
+			idx, ok := p.contigToIdx[rec[0]]
+			if !ok {
+				continue
+			}
+			depth, err := strconv.ParseFloat(rec[1], 64)
+			if err != nil {
+				log.Fatalf("Invalid depth of contig %s in `%s`: %s", rec[0], r.CoverageFile, err)
+			}
+			r.coverage[idx] = depth
+			nFound++
+		}
+		log.Noticef("Loaded coverage of %d contigs from `%s`", nFound, r.CoverageFile)
+		return
+	}
+	if p.CisFile == "" {
+		p.CisFile = RemoveExt(RemoveExt(r.PairsFile)) + ".cis.txt"
+		if _, err := os.Stat(p.CisFile); err != nil {
+			log.Warningf("No coverage found (--coverage or `%s`), links normalized by RE sites only", p.CisFile)
+			p.CisFile = ""
+			return
+		}
+	}
+	p.readCisTrans()
+	for i, contig := range p.contigs {
+		if contig.length > 0 {
+			r.coverage[i] = float64(contig.cisLinks+contig.transLinks) / float64(contig.length)
+		}
+	}
+}
+
+// normalizeCoverage scales the RE-normalized links between contigs i and j by
+// median / sqrt(coverage_i * coverage_j), so that the links within the genomes
+// at high and low abundance are comparable. Contigs without coverage are left
+// as is.
+func (r *Binner) normalizeCoverage() {
+	covered := []float64{}
+	for _, c := range r.coverage {
+		if c > 0 {
+			covered = append(covered, c)
+		}
+	}
+	if len(covered) == 0 {
+		return
+	}
+	medianCoverage := median(covered)
+	M := r.p.matrix
+	for i := range M {
+		for j, w := range M[i] {
+			if r.coverage[i] <= 0 || r.coverage[j] <= 0 {
+				continue
+			}
+			M[i][j] = int64(math.Ceil(float64(w) * medianCoverage / math.Sqrt(r.coverage[i]*r.coverage[j])))
+		}
+	}
+	log.Noticef("Links normalized by coverage of %d contigs (median = %.4g)", len(covered), medianCoverage)
+}
+
+// findBins detects the communities in the contig graph as the bins. The
+// communities shorter than MinBinSize, such as plasmids and fragments, are
+// attached to the bin (host) they link to the most, if the links are at least
+// BinHostRatio times those to any other bin. Unlinked contigs with dense cis
+// links are kept as their own bins, as they are likely complete circular
+// replicons.
+func (r *Binner) findBins() {
+	p := r.p
+	g, nodes := p.makeCommunityGraph()
+	log.Noticef("Community detection (method = %s, resolution = %g) starts with %d informative contigs",
+		r.Method, r.Resolution, len(nodes))
+	var communities []int
+	switch r.Method {
+	case MethodLouvain:
+		communities = louvain(g, r.Resolution, false)
+	case MethodLeiden:
+		communities = louvain(g, r.Resolution, true)
+	default:
+		log.Fatalf("Unknown bin method: %s, expecting %s or %s", r.Method, MethodLouvain, MethodLeiden)
+	}
+
+	groups := map[int][]int{}
+	for a, i := range nodes {
+		groups[communities[a]] = append(groups[communities[a]], i)
+	}
+	keys := make([]int, 0, len(groups))
+	for c := range groups {
+		keys = append(keys, c)
+	}
+	sort.Ints(keys)
+
+	r.roles = map[int]string{}
+	small := [][]int{}
+	for _, c := range keys {
+		if p.clusterLength(groups[c]) >= r.MinBinSize {
+			r.bins = append(r.bins, groups[c])
+			for _, i := range groups[c] {
+				r.roles[i] = "CORE"
+			}
+		} else {
+			small = append(small, groups[c])
+		}
+	}
+
+	nAttached, nReplicons := 0, 0
+	attached := make([][]int, len(r.bins))
+	for _, group := range small {
+		best, bestLinkage, secondLinkage := -1, 0.0, 0.0
+		for b, bin := range r.bins {
+			linkage := p.clusterLinkage(group, bin) * float64(len(bin)) // Total links to the bin
+			if linkage > bestLinkage {
+				best, secondLinkage, bestLinkage = b, bestLinkage, linkage
+			} else if linkage > secondLinkage {
+				secondLinkage = linkage
+			}
+		}
+		if best != -1 && bestLinkage >= BinHostRatio*secondLinkage {
+			attached[best] = append(attached[best], group...)
+			for _, i := range group {
+				r.roles[i] = "ATTACHED"
+			}
+			nAttached += len(group)
+			continue
+		}
+		if best == -1 && len(group) == 1 && r.isReplicon(group[0]) {
+			r.bins = append(r.bins, group)
+			r.roles[group[0]] = "REPLICON"
+			nReplicons++
+			continue
+		}
+		for _, i := range group {
+			r.roles[i] = "AMBIGUOUS"
+			if best == -1 {
+				r.roles[i] = "UNLINKED"
+			}
+		}
+	}
+	for b := range attached {
+		r.bins[b] = append(r.bins[b], attached[b]...)
+	}
+
+	// Bins are ordered by total length
+	sort.SliceStable(r.bins, func(a, b int) bool {
+		return p.clusterLength(r.bins[a]) > p.clusterLength(r.bins[b])
+	})
+	for _, bin := range r.bins {
+		sort.Ints(bin)
+	}
+	log.Noticef("Found %d bins (MinBinSize = %d), with %d contigs attached to hosts and %d standalone replicons",
+		len(r.bins), r.MinBinSize, nAttached, nReplicons)
+}
+
+// isReplicon returns true if the contig has dense cis links but nearly no
+// trans links, such as complete circular chromosomes or plasmids
+func (r *Binner) isReplicon(i int) bool {
+	contig := r.p.contigs[i]
+	cis, trans := contig.cisLinks, contig.transLinks
+	return r.p.hasCisTrans && cis >= BinMinCisLinks &&
+		float64(trans) <= OrganelleMaxTransFraction*float64(cis+trans)
+}
+
+// binLabel returns the name of the b-th bin
+func binLabel(b int) string {
+	return fmt.Sprintf("bin%03d", b+1)
+}
+
+// writeBins writes the bin, length, RE counts, coverage and role of each contig
+func (r *Binner) writeBins() {
+	p := r.p
+	r.OutBinsfile = RemoveExt(RemoveExt(r.PairsFile)) + ".bins.txt"
+	f, err := os.Create(r.OutBinsfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	contigToBin := map[int]int{}
+	for b, bin := range r.bins {
+		for _, i := range bin {
+			contigToBin[i] = b
+		}
+	}
+	fmt.Fprintf(w, BinsHeader)
+	for i, contig := range p.contigs {
+		bin, role := "-", r.roles[i]
+		if b, ok := contigToBin[i]; ok {
+			bin = binLabel(b)
+		}
+		if role == "" {
+			role = "UNLINKED"
+			if contig.skip {
+				role = contig.skipReason
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.4g\t%s\n",
+			bin, contig.name, contig.length, contig.recounts, r.coverage[i], role)
+	}
+	w.Flush()
+	log.Noticef("Bins of %d contigs written to `%s`", len(p.contigs), r.OutBinsfile)
+}
+
+// writeBinsFasta writes the contigs of each bin into bin001.fasta etc.
+func (r *Binner) writeBinsFasta() {
+	p := r.p
+	prefix := RemoveExt(RemoveExt(r.PairsFile))
+	contigToBin := map[string]int{}
+	for b, bin := range r.bins {
+		for _, i := range bin {
+			contigToBin[p.contigs[i].name] = b
+		}
+	}
+	writers := make([]*xopen.Writer, len(r.bins))
+	readFasta(r.Fastafile, func(name string, s []byte) {
+		b, ok := contigToBin[name]
+		if !ok {
+			return
+		}
+		if writers[b] == nil {
+			outfile := fmt.Sprintf("%s.%s.fasta", prefix, binLabel(b))
+			fh, err := xopen.Wopen(outfile)
+			ErrorAbort(err)
+			writers[b] = fh
+		}
+		var buf bytes.Buffer
+		buf.Write(s)
+		writeRecord(name, buf, writers[b])
+	})
+	for _, fh := range writers {
+		if fh != nil {
+			fh.Close()
+		}
+	}
+	log.Noticef("FASTA of %d bins written to `%s.bin*.fasta`", len(r.bins), prefix)
+}
@@ -0,0 +1,51 @@
+/*
+ *  bin_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindBins(t *testing.T) {
+	// Genome A (0-2) at depth 10 and genome B (3-5) at depth 40, a plasmid
+	// (6-7) weakly linked to A, and two contigs (8, 9) without links
+	links := map[[2]int]int64{
+		{0, 1}: 100, {0, 2}: 100, {1, 2}: 100,
+		{3, 4}: 400, {3, 5}: 400, {4, 5}: 400,
+		{6, 7}: 1000, {0, 6}: 5,
+	}
+	p := newTestPartitioner(links, 10)
+	p.hasCisTrans = true
+	// Contig 8 has dense cis links as a circular replicon
+	p.contigs[8].cisLinks = 1000
+	p.contigs[9].cisLinks = 10
+	r := &Binner{p: p, Method: MethodLouvain, Resolution: 1, MinBinSize: 250000,
+		coverage: []float64{10, 10, 10, 40, 40, 40, 10, 10, 0, 0}}
+
+	// The links of B are scaled by median / depth = 10 / 40
+	r.normalizeCoverage()
+	if p.matrix[3][4] != 100 || p.matrix[0][1] != 100 || p.matrix[6][7] != 1000 {
+		t.Errorf("Expected the links of B scaled to those of A, got %v", p.matrix)
+	}
+
+	r.findBins()
+	expectedRoles := []string{"CORE", "CORE", "CORE", "CORE", "CORE", "CORE",
+		"ATTACHED", "ATTACHED", "REPLICON", "UNLINKED"}
+	for i, expected := range expectedRoles {
+		if r.roles[i] != expected {
+			t.Errorf("Expected contig %d to be %s, got %s", i, expected, r.roles[i])
+		}
+	}
+	// The plasmid is in the bin of A, which is longer than B
+	expectedBins := "[[0 1 2 6 7] [3 4 5] [8]]"
+	if got := fmt.Sprint(r.bins); got != expectedBins {
+		t.Errorf("Expected bins %s, got %s", expectedBins, got)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ *  classify.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// readCisTrans reads the cis and trans links per contig written by extract
+// #Contig    CisLinks    TransLinks
+func (r *Partitioner) readCisTrans() {
+	recs := ReadCSVLines(r.CisFile)
+	nFound := 0
+	for _, rec := range recs {
+		idx, ok := r.contigToIdx[rec[0]]
+		if !ok {
+			continue
+		}
+		contig := r.contigs[idx]
+		contig.cisLinks, _ = strconv.Atoi(rec[1])
+		contig.transLinks, _ = strconv.Atoi(rec[2])
+		nFound++
+	}
+	r.hasCisTrans = true
+	log.Noticef("Loaded cis and trans links of %d contigs from `%s`", nFound, r.CisFile)
+}
+
+// classifyContigs assigns each contig to one of the categories:
+// ORGANELLE - cis links are OrganelleMinCoverage times as dense as the median
+// contig and nearly no trans links, likely organelle or contaminant
+// SHORT - too few RE sites (MinREs)
+// REPETITIVE - too many Hi-C links compared to average (MaxLinkDensity)
+// ISOLATED - no links to other contigs
+// INFORMATIVE - used in the clustering
+func (r *Partitioner) classifyContigs() []string {
+	medianDensity := 0.0
+	if r.hasCisTrans {
+		densities := []float64{}
+		for _, contig := range r.contigs {
+			if contig.length > 0 {
+				densities = append(densities, float64(contig.cisLinks)/float64(contig.length))
+			}
+		}
+		if len(densities) > 0 {
+			medianDensity = median(densities)
+		}
+	}
+
+	categories := make([]string, len(r.contigs))
+	for i, contig := range r.contigs {
+		cis, trans := contig.cisLinks, contig.transLinks
+		switch {
+		case r.hasCisTrans && contig.length > 0 && cis > 0 &&
+			float64(cis)/float64(contig.length) >= OrganelleMinCoverage*medianDensity &&
+			float64(trans) <= OrganelleMaxTransFraction*float64(cis+trans):
+			categories[i] = "ORGANELLE"
+		case contig.skipReason != "":
+			categories[i] = contig.skipReason
+		case len(r.matrix[i]) == 0:
+			categories[i] = "ISOLATED"
+		default:
+			categories[i] = "INFORMATIVE"
+		}
+	}
+	return categories
+}
+
+// writeContigClasses writes the classification of all contigs, along with the
+// evidence and the cluster each contig is assigned to
+func (r *Partitioner) writeContigClasses() {
+	contigToCluster := map[int]int{}
+	for cID, cl := range r.clusters {
+		for _, id := range cl {
+			contigToCluster[id] = cID
+		}
+	}
+	categories := r.classifyContigs()
+
+	outfile := RemoveExt(RemoveExt(r.PairsFile)) + ".contigs.class.txt"
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	counts := map[string]int{}
+	fmt.Fprintf(w, ContigClassHeader)
+	for i, contig := range r.contigs {
+		cis, trans, ratio := "NA", "NA", "NA"
+		if r.hasCisTrans {
+			cis, trans = strconv.Itoa(contig.cisLinks), strconv.Itoa(contig.transLinks)
+			if contig.transLinks > 0 {
+				ratio = fmt.Sprintf("%.2f", float64(contig.cisLinks)/float64(contig.transLinks))
+			}
+		}
+		cluster := "-"
+		if cID, ok := contigToCluster[i]; ok {
+			cluster = r.clusterLabel(cID)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%s\t%s\t%s\t%s\t%s\n",
+			contig.name, contig.recounts, contig.length, contig.linkFactor,
+			cis, trans, ratio, categories[i], cluster)
+		counts[categories[i]]++
+	}
+	w.Flush()
+	log.Noticef("Contig classes written to `%s` (INFORMATIVE: %d, SHORT: %d, REPETITIVE: %d, ISOLATED: %d, ORGANELLE: %d)",
+		outfile, counts["INFORMATIVE"], counts["SHORT"], counts["REPETITIVE"], counts["ISOLATED"], counts["ORGANELLE"])
+}
@@ -0,0 +1,29 @@
+/*
+ *  classify_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import "testing"
+
+func TestClassifyOrganelle(t *testing.T) {
+	r := newTestPartitioner(map[[2]int]int64{{0, 1}: 10, {1, 2}: 10, {2, 3}: 10}, 4)
+	r.hasCisTrans = true
+	for i, contig := range r.contigs {
+		contig.cisLinks, contig.transLinks = 1000, 100
+		// The observed links are counted separately in the clustering
+		contig.nObservedLinks = 1000 * (i + 1)
+	}
+	// Dense cis links with nearly no trans links
+	r.contigs[3].cisLinks, r.contigs[3].transLinks = 10000, 10
+	categories := r.classifyContigs()
+	for i, expected := range []string{"INFORMATIVE", "INFORMATIVE", "INFORMATIVE", "ORGANELLE"} {
+		if categories[i] != expected {
+			t.Errorf("Expected contig %d to be %s, got %s", i, expected, categories[i])
+		}
+	}
+}
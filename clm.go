@@ -86,8 +86,9 @@ type Tig struct {
 
 // Tour stores a number of tigs along with 2D matrices for evaluation
 type Tour struct {
-	Tigs []Tig
-	M    [][]int
+	Tigs      []Tig
+	M         [][]int
+	crossover string // Crossover operator in GA
 }
 
 // RECountsRecord contains a line in the RE file
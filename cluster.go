@@ -11,7 +11,9 @@ package allhic
 
 import (
 	"bufio"
+	"container/heap"
 	"fmt"
+	"math"
 	"os"
 	"sort"
 	"strings"
@@ -19,9 +21,30 @@ import (
 
 // merge is a generic type that stores the merges
 type merge struct {
-	a     int
-	b     int
-	score float64
+	a         int
+	b         int
+	score     float64
+	seq       int     // Insertion order, used to break ties between equal scores
+	conflicts int64   // Number of hard cannot-links between the two clusters
+	soft      float64 // Log of the multiplier on the score from soft constraints
+	forced    bool    // Forced by hard must-links
+}
+
+// mergeQueue is a max-heap of merges, ordered by score then insertion order
+type mergeQueue []*merge
+
+func (q mergeQueue) Len() int { return len(q) }
+func (q mergeQueue) Less(i, j int) bool {
+	return q[i].score > q[j].score || (q[i].score == q[j].score && q[i].seq < q[j].seq)
+}
+func (q mergeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *mergeQueue) Push(x interface{}) { *q = append(*q, x.(*merge)) }
+func (q *mergeQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
 }
 
 // Clusters stores all the contig IDs per cluster
@@ -39,31 +62,208 @@ type linkage struct {
 	cID        int
 }
 
+// hierarchy stores the state of the average-linkage hierarchical clustering.
+// Clusters 0..N-1 are the contigs, and the cluster created by the k-th merge
+// gets the ID of N+k.
+type hierarchy struct {
+	G             LinkMatrix
+	N             int
+	clusterID     []int   // Current cluster of each contig, -1 if skipped
+	clusterSize   []int   // Number of contigs in each cluster
+	clusterExists []bool  // Whether the cluster is still active
+	members       [][]int // Contigs in each cluster
+	// Total links to each cluster from the other clusters, link[a][b] is the
+	// sum of G[i][j] for contig i in b and contig j in a
+	link []map[int]int64
+	// Number of hard cannot-links and the sum of the soft constraints between
+	// the clusters, nil if there are no constraints
+	conflicts []map[int]int64
+	softLinks []map[int]float64
+	nBlocked  int // Number of best merges rejected due to cannot-links
+	queue     mergeQueue
+	nMerges   int
+	seq       int
+	history   []*merge // Merges in order, the k-th merge creates cluster N+k
+}
+
+// newHierarchy starts the clustering with each contig in its own cluster
+func newHierarchy(G LinkMatrix, contigs []*ContigInfo) *hierarchy {
+	N := len(contigs)
+	h := &hierarchy{
+		G:             G,
+		N:             N,
+		clusterID:     make([]int, N),
+		clusterSize:   make([]int, 2*N),
+		clusterExists: make([]bool, 2*N),
+		members:       make([][]int, 2*N),
+		link:          make([]map[int]int64, 2*N),
+	}
+	for i, contig := range contigs {
+		if contig.skip {
+			h.clusterID[i] = -1
+			continue
+		}
+		h.clusterID[i] = i
+		h.clusterSize[i] = 1
+		h.clusterExists[i] = true
+		h.members[i] = []int{i}
+	}
+	for j, contig := range contigs {
+		if contig.skip {
+			continue
+		}
+		h.link[j] = map[int]int64{}
+		for i := range G[j] {
+			if i != j && !contigs[i].skip {
+				h.link[j][i] = G[i][j]
+			}
+		}
+	}
+	return h
+}
+
+// push adds a potential merge into the queue
+func (h *hierarchy) push(a, b int, score float64) {
+	m := &merge{a: a, b: b, score: score, seq: h.seq}
+	if h.conflicts != nil {
+		m.conflicts = h.conflicts[a][b]
+		m.soft = h.softLinks[a][b]
+		m.score *= math.Exp(m.soft)
+	}
+	heap.Push(&h.queue, m)
+	h.seq++
+}
+
+// pop returns the best merge between two active clusters, or nil if there are
+// no more merges. The merges involving clusters that no longer exist are
+// stale and lazily discarded here, so are the merges between clusters with
+// hard cannot-links.
+func (h *hierarchy) pop() *merge {
+	for h.queue.Len() > 0 {
+		m := heap.Pop(&h.queue).(*merge)
+		if !h.clusterExists[m.a] || !h.clusterExists[m.b] {
+			continue
+		}
+		if m.conflicts > 0 {
+			h.nBlocked++
+			continue
+		}
+		return m
+	}
+	return nil
+}
+
+// mergeClusters merges cluster a and b into a new cluster and returns its ID.
+// The smaller cluster is always merged into the larger one, so each contig is
+// only moved O(log N) times.
+func (h *hierarchy) mergeClusters(a, b int) int {
+	newClusterID := h.N + h.nMerges
+	h.clusterExists[a] = false
+	h.clusterExists[b] = false
+	h.clusterExists[newClusterID] = true
+	h.clusterSize[newClusterID] = h.clusterSize[a] + h.clusterSize[b]
+
+	large, small := a, b
+	if len(h.members[large]) < len(h.members[small]) {
+		large, small = small, large
+	}
+	for _, i := range h.members[small] {
+		h.clusterID[i] = newClusterID
+	}
+	for _, i := range h.members[large] {
+		h.clusterID[i] = newClusterID
+	}
+	h.members[newClusterID] = append(h.members[large], h.members[small]...)
+
+	// Links of the new cluster are the sum of the links of the two clusters
+	mergeCounts(h.link, a, b, newClusterID)
+	if h.conflicts != nil {
+		mergeCounts(h.conflicts, a, b, newClusterID)
+		mergeWeights(h.softLinks, a, b, newClusterID)
+	}
+	h.members[a], h.members[b] = nil, nil
+	h.nMerges++
+	return newClusterID
+}
+
+// mergeCounts combines the counts between clusters when cluster a and b are
+// merged into newClusterID, where counts[a][c] is the count between a and c
+func mergeCounts(counts []map[int]int64, a, b, newClusterID int) {
+	large, small := a, b
+	if len(counts[large]) < len(counts[small]) {
+		large, small = small, large
+	}
+	merged := counts[large]
+	if merged == nil {
+		merged = map[int]int64{}
+	}
+	for c, w := range counts[small] {
+		merged[c] += w
+	}
+	delete(merged, a)
+	delete(merged, b)
+	for c := range merged {
+		cCounts := counts[c]
+		if w := cCounts[a] + cCounts[b]; w != 0 {
+			cCounts[newClusterID] = w
+		}
+		delete(cCounts, a)
+		delete(cCounts, b)
+	}
+	counts[newClusterID] = merged
+	counts[a], counts[b] = nil, nil
+}
+
+// linkagesTo returns the IDs of the clusters linked to the given cluster in
+// ascending order, along with the total links to the cluster
+func (h *hierarchy) linkagesTo(cID int) ([]int, map[int]int64) {
+	totalLinkageByCluster := h.link[cID]
+	linked := make([]int, 0, len(totalLinkageByCluster))
+	for i := range totalLinkageByCluster {
+		linked = append(linked, i)
+	}
+	sort.Ints(linked)
+	return linked, totalLinkageByCluster
+}
+
 // Cluster performs the hierarchical clustering
 // This function is a re-implementation of the AHClustering() function in LACHESIS
+// The complete hierarchy is kept in the history, so that the merges can be cut
+// at other levels than K, and the K clusters are cut from it.
 func (r *Partitioner) Cluster() {
+	h := r.hierarchicalClustering(0)
+	r.history = h.history
+	r.setClusters(cutHierarchy(r.contigs, h.history, r.K))
+}
+
+// hierarchicalClustering merges the clusters until there are nclusters
+// non-singleton clusters left, or no more merges can be made. Use nclusters of
+// 0 to build the complete hierarchy.
+func (r *Partitioner) hierarchicalClustering(nclusters int) *hierarchy {
 	// LACHESIS also skips contigs that are thought to be centromeric
 	G := r.matrix
-	nclusters := r.K
 	N := len(r.contigs)
 
 	// Auxiliary data structures to facilitate cluster merging
-	clusterID := make([]int, N)
-	clusterSize := make([]int, 2*N)
-	clusterExists := make([]bool, 2*N)
+	h := newHierarchy(G, r.contigs)
+	nForced := 0
+	if len(r.constraints) > 0 {
+		h.setConstraints(r.constraints)
+		nForced = h.forceMustLinks(r.constraints)
+	}
 	nonSingletonClusters := 0
+	for i := N; i < N+h.nMerges; i++ {
+		if h.clusterExists[i] {
+			nonSingletonClusters++
+		}
+	}
 
 	nContigsSkipped := 0
 	// Initially all contigs in their own cluster
-	for i, contig := range r.contigs {
+	for _, contig := range r.contigs {
 		if contig.skip {
-			clusterID[i] = -1
 			nContigsSkipped++
-			continue
 		}
-		clusterID[i] = i
-		clusterSize[i] = 1
-		clusterExists[i] = true
 	}
 	nNonSkipped := N - nContigsSkipped
 	if nNonSkipped == 0 {
@@ -72,50 +272,43 @@ func (r *Partitioner) Cluster() {
 	log.Noticef("Clustering starts with %d (%d informative) contigs with target of %d clusters",
 		N, nNonSkipped, nclusters)
 
-	// mergeScores has all possible pairwise merge scores
-	// We keep a slice containing all potential merges. Best to use a priority queue.
-	// The original LACHESIS implementation used a C++ multimap with its use similar
-	// to a priority queue, however, the performance benefit is not obvious since we
-	// need to perform updates to all merges (remove old merges and insert new merges)
-	merges := []*merge{}
-
-	for i := 0; i < N; i++ {
-		if r.contigs[i].skip {
+	// The merge queue has all possible pairwise merge scores. Similar to the
+	// C++ multimap in LACHESIS, the merges are kept in a priority queue. Merges
+	// involving the clusters that no longer exist are removed lazily when they
+	// surface. Ties are broken by the order the merges were inserted.
+	for i := 0; i < N+h.nMerges; i++ {
+		if !h.clusterExists[i] {
 			continue
 		}
-		for j := i + 1; j < N; j++ {
-			if !r.contigs[j].skip && G[i][j] > MinAvgLinkage {
-				merges = append(merges, &merge{
-					a:     i,
-					b:     j,
-					score: float64(G[i][j]),
-				})
+		linked, _ := h.linkagesTo(i)
+		for _, j := range linked {
+			if j < i {
+				continue
+			}
+			// Same as G[i][j] between contigs
+			avgLinkage := float64(h.link[j][i]) / float64(h.clusterSize[i]) / float64(h.clusterSize[j])
+			if avgLinkage > MinAvgLinkage {
+				h.push(i, j, avgLinkage)
 			}
 		}
 	}
 
-	nMerges := 0
 	// The core hierarchical clustering
+	nSoftMerges := 0
 	for {
-		if len(merges) == 0 {
+		// Step 1. Find the pairs of the clusters with the highest merge score
+		bestMerge := h.pop()
+		if bestMerge == nil {
 			log.Notice("No more merges to do since the queue is empty")
 			break
 		}
-		bestMerge := merges[0]
-		// Step 1. Find the pairs of the clusters with the highest merge score
-		for _, merge := range merges {
-			if merge.score > bestMerge.score {
-				bestMerge = merge
-			}
-		}
 
 		// Step 2. Merge the contig pair
-		newClusterID := N + nMerges
-
-		clusterExists[bestMerge.a] = false
-		clusterExists[bestMerge.b] = false
-		clusterExists[newClusterID] = true
-		clusterSize[newClusterID] = clusterSize[bestMerge.a] + clusterSize[bestMerge.b]
+		if bestMerge.soft != 0 {
+			nSoftMerges++
+		}
+		newClusterID := h.mergeClusters(bestMerge.a, bestMerge.b)
+		h.history = append(h.history, bestMerge)
 		if bestMerge.a < N {
 			nonSingletonClusters++
 		}
@@ -124,84 +317,98 @@ func (r *Partitioner) Cluster() {
 		}
 		nonSingletonClusters--
 
-		var newCluster []int
-		for i := 0; i < N; i++ {
-			if clusterID[i] == bestMerge.a || clusterID[i] == bestMerge.b {
-				clusterID[i] = newClusterID
-				newCluster = append(newCluster, i)
-			}
-		}
-
-		nMerges++
-
 		// Step 3. Calculate new score entries for the new cluster
-		// Remove all used clusters
-		newMerges := []*merge{}
-		for _, merge := range merges {
-			if clusterExists[merge.a] && clusterExists[merge.b] {
-				newMerges = append(newMerges, merge)
-			} else {
-				// fmt.Println("Ignore", merge)
-			}
-		}
-
-		// Add all merges with the new cluster
-		totalLinkageByCluster := make([]int64, 2*N)
-		for i := 0; i < N; i++ {
-			cID := clusterID[i]
-			if cID == newClusterID { // No need to calculate linkages within cluster
-				continue
-			}
-			if cID == -1 { // This happens if contig is skipped
-				continue
-			}
-			for _, j := range newCluster {
-				totalLinkageByCluster[cID] += G[i][j]
-			}
-		}
-
-		for i := 0; i < 2*N; i++ {
+		linked, totalLinkageByCluster := h.linkagesTo(newClusterID)
+		for _, i := range linked {
 			if totalLinkageByCluster[i] <= 0 {
 				continue
 			}
-			if !clusterExists[i] {
+			if !h.clusterExists[i] {
 				log.Errorf("Cluster %d does not exist", i)
 			}
 			// Average linkage
-			avgLinkage := float64(totalLinkageByCluster[i]) / float64(clusterSize[i]) /
-				float64(clusterSize[newClusterID])
+			avgLinkage := float64(totalLinkageByCluster[i]) / float64(h.clusterSize[i]) /
+				float64(h.clusterSize[newClusterID])
 
 			if avgLinkage < MinAvgLinkage {
 				continue
 			}
-
-			p := &merge{
-				a:     min(i, newClusterID),
-				b:     max(i, newClusterID),
-				score: avgLinkage,
-			}
-			newMerges = append(newMerges, p)
-			// fmt.Println("Insert", p)
+			h.push(min(i, newClusterID), max(i, newClusterID), avgLinkage)
 		}
 
 		// Analyze the current clusters if enough merges occurred
-		if nMerges > nNonSkipped/2 && nonSingletonClusters <= nclusters {
+		if h.nMerges > nNonSkipped/2 && nonSingletonClusters <= nclusters {
 			if nonSingletonClusters == nclusters {
 				log.Noticef("%d merges made so far; this leaves %d clusters, and so we'r done!",
-					nMerges, nonSingletonClusters)
+					h.nMerges, nonSingletonClusters)
 				break
 			}
 		}
 
-		if nMerges%50 == 0 {
+		if h.nMerges%50 == 0 {
 			log.Noticef("Merge #%d: Clusters\t%d + %d -> %d, Linkage = %g",
-				nMerges, bestMerge.a, bestMerge.b, newClusterID, bestMerge.score)
+				h.nMerges, bestMerge.a, bestMerge.b, newClusterID, bestMerge.score)
 
 		}
-		merges = newMerges
 	}
+	if h.conflicts != nil {
+		log.Noticef("Constraints: %d merges forced by must-links, %d merges blocked by cannot-links, %d merges made with soft constraints",
+			nForced, h.nBlocked, nSoftMerges)
+	}
+	return h
+}
 
-	r.setClusters(clusterID)
+// cutHierarchy replays the merges in the hierarchy and stops at the same point
+// as hierarchicalClustering() would with the target of nclusters, which gives
+// the same clusterID without clustering again. The merges forced by the
+// must-links are made before the clustering, and are always replayed.
+func cutHierarchy(contigs []*ContigInfo, history []*merge, nclusters int) []int {
+	N := len(contigs)
+	parent := make([]int, 2*N)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	nNonSkipped := 0
+	for _, contig := range contigs {
+		if !contig.skip {
+			nNonSkipped++
+		}
+	}
+
+	nonSingletonClusters := 0
+	for k, m := range history {
+		parent[m.a] = N + k
+		parent[m.b] = N + k
+		if m.a < N {
+			nonSingletonClusters++
+		}
+		if m.b < N {
+			nonSingletonClusters++
+		}
+		nonSingletonClusters--
+		if m.forced {
+			continue
+		}
+		if k+1 > nNonSkipped/2 && nonSingletonClusters == nclusters {
+			break
+		}
+	}
+
+	clusterID := make([]int, N)
+	for i, contig := range contigs {
+		clusterID[i] = -1
+		if !contig.skip {
+			clusterID[i] = find(i)
+		}
+	}
+	return clusterID
 }
 
 // setClusters assigns contigs into clusters per clusterID
@@ -232,15 +439,29 @@ func (r *Partitioner) setClusters(clusterID []int) {
 	nPassRatio := 0
 	nFailRatio := 0
 	nFailCluster := 0
+	nMustLink := 0
 	skippedClusters := map[int]int{}
 
+	contigToCluster := map[int]int{}
+	for cID, cl := range r.clusters {
+		for _, id := range cl {
+			contigToCluster[id] = cID
+		}
+	}
+
 	// NonInformativeRatio > 1
 	// Loop through all skipped contigs. Determine the cluster with largest average linkage.
 	for i := 0; i < N; i++ {
 		if clusterID[i] != -1 {
 			continue
 		}
-		linkages := r.findClusterLinkage(i)
+		if cID, ok := r.mustLinkCluster(i, contigToCluster); ok {
+			skippedClusters[i] = cID
+			nMustLink++
+			continue
+		}
+		linkages := r.findClusterLinkage(i, contigToCluster)
+		linkages = r.constrainLinkages(i, linkages, contigToCluster)
 		if len(linkages) == 0 { // Didn't cluster with any
 			nFailCluster++
 			continue
@@ -263,10 +484,15 @@ func (r *Partitioner) setClusters(clusterID []int) {
 
 	log.Noticef("setClusters summary (NonInformativeRatio = %d): nPassRatio = %d, nFailRatio = %d, nFailCluster=%d",
 		r.NonInformativeRatio, nPassRatio, nFailRatio, nFailCluster)
+	if nMustLink > 0 {
+		log.Noticef("%d skipped contigs assigned to clusters by must-links", nMustLink)
+	}
 
 	// Insert the skipped contigs into clusters
+	r.recovered = map[int]bool{}
 	for contigID, cID := range skippedClusters {
 		r.clusters[cID] = append(r.clusters[cID], contigID)
+		r.recovered[contigID] = true
 	}
 
 	r.sortClusters()
@@ -274,20 +500,21 @@ func (r *Partitioner) setClusters(clusterID []int) {
 	return
 }
 
-// findClusterLinkages
-func (r *Partitioner) findClusterLinkage(contigID int) []*linkage {
-	linkages := []*linkage{}
-	for i, cl := range r.clusters {
-		totalLinkage := int64(0)
-		clusterSize := len(cl)
+// findClusterLinkages calculates the average linkage between a contig and
+// each cluster, only visiting the contigs linked to it
+func (r *Partitioner) findClusterLinkage(contigID int, contigToCluster map[int]int) []*linkage {
+	totalLinkageByCluster := map[int]int64{}
+	for id, w := range r.matrix[contigID] {
+		if cID, ok := contigToCluster[id]; ok && id != contigID {
+			totalLinkageByCluster[cID] += w
+		}
+	}
 
-		// Calculate the average linkage between contig and the cluster
-		for _, id := range cl {
-			if contigID == id { // contig in this contig
-				clusterSize--
-			} else {
-				totalLinkage += r.matrix[contigID][id]
-			}
+	linkages := []*linkage{}
+	for i, totalLinkage := range totalLinkageByCluster {
+		clusterSize := len(r.clusters[i])
+		if cID, ok := contigToCluster[contigID]; ok && cID == i { // contig in this cluster
+			clusterSize--
 		}
 		if totalLinkage > 0 {
 			linkages = append(linkages, &linkage{
@@ -325,9 +552,14 @@ func (r *Partitioner) sortClusters() {
 	r.clusters = newClusters
 }
 
-// printClusters shows the contents of the clusters
+// printClusters shows the contents of the clusters. The clusters taken from
+// --from-clusters are not written back onto the same file.
 func (r *Partitioner) printClusters() {
 	clusterfile := RemoveExt(RemoveExt(r.PairsFile)) + ".clusters.txt"
+	if r.FromClusters != "" && IsSameFile(r.FromClusters, clusterfile) {
+		log.Noticef("Clusters are taken from `%s`, which is kept as is", clusterfile)
+		return
+	}
 	f, _ := os.Create(clusterfile)
 	defer f.Close()
 	w := bufio.NewWriter(f)
@@ -342,7 +574,7 @@ func (r *Partitioner) printClusters() {
 		sort.Strings(names)
 
 		// fmt.Printf("%dg%d\t%d\t%s\n", r.K, j+1, len(names), strings.Join(names, " "))
-		fmt.Fprintf(w, "%dg%d\t%d\t%s\n", r.K, j+1, len(names), strings.Join(names, " "))
+		fmt.Fprintf(w, "%s\t%d\t%s\n", r.clusterLabel(j), len(names), strings.Join(names, " "))
 	}
 	w.Flush()
 
@@ -0,0 +1,104 @@
+/*
+ *  cluster_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newTestPartitioner makes a Partitioner of the contigs with the given links
+// between them, each contig is 100 kb
+func newTestPartitioner(links map[[2]int]int64, N int) *Partitioner {
+	r := &Partitioner{matrix: NewLinkMatrix(N)}
+	for i := 0; i < N; i++ {
+		r.contigs = append(r.contigs, &ContigInfo{name: fmt.Sprintf("tig%d", i), recounts: 100, length: 100000})
+	}
+	for ab, w := range links {
+		r.matrix[ab[0]][ab[1]] = w
+		r.matrix[ab[1]][ab[0]] = w
+	}
+	return r
+}
+
+// sameCluster checks if the two contigs end up in the same cluster
+func sameCluster(h *hierarchy, a, b int) bool {
+	return h.clusterID[a] == h.clusterID[b]
+}
+
+func TestAllelicMerges(t *testing.T) {
+	// Contigs 0 and 1 are allelic and have the most links between them
+	links := map[[2]int]int64{{0, 1}: 100, {2, 3}: 90, {0, 2}: 50, {1, 3}: 50}
+
+	// The merge 0-1 is rejected at first, and again after 0 merges with 2-3,
+	// the stale merges are not counted
+	r := newTestPartitioner(links, 4)
+	r.addConstraint(Constraint{a: 0, b: 1, weight: 1, allelic: true})
+	h := r.hierarchicalClustering(0)
+	if sameCluster(h, 0, 1) {
+		t.Errorf("Expected allelic contigs 0 and 1 in different clusters, got %v", h.clusterID)
+	}
+	if !sameCluster(h, 0, 2) || !sameCluster(h, 2, 3) {
+		t.Errorf("Expected contigs 0, 2 and 3 in the same cluster, got %v", h.clusterID)
+	}
+	if h.nBlocked != 2 {
+		t.Errorf("Expected 2 merges blocked, got %d", h.nBlocked)
+	}
+
+	// With a penalty below 1, the merge 0-1 is made after 2-3
+	r = newTestPartitioner(links, 4)
+	r.addConstraint(Constraint{a: 0, b: 1, weight: .5, allelic: true})
+	h = r.hierarchicalClustering(0)
+	if h.nBlocked != 0 {
+		t.Errorf("Expected no merges blocked, got %d", h.nBlocked)
+	}
+	if len(h.history) < 2 || h.history[0].a != 2 || h.history[1].a != 0 || h.history[1].b != 1 {
+		t.Errorf("Expected merges 2-3 and then 0-1, got %v", h.history)
+	}
+}
+
+func TestCutHierarchy(t *testing.T) {
+	links := map[[2]int]int64{{0, 1}: 10, {2, 3}: 10, {4, 5}: 10, {1, 2}: 5, {3, 4}: 5,
+		{5, 6}: 20, {6, 7}: 20, {7, 8}: 3}
+	// The forced merges already leave a single cluster after half of the
+	// contigs are merged, but the clustering only checks k after them
+	must := [][2]int{{0, 1}, {2, 3}, {4, 5}, {0, 2}, {0, 4}}
+	setup := func() *Partitioner {
+		r := newTestPartitioner(links, 9)
+		for _, ab := range must {
+			r.addConstraint(Constraint{a: ab[0], b: ab[1], must: true, weight: 1})
+		}
+		return r
+	}
+	full := setup().hierarchicalClustering(0)
+	for k := 1; k <= 4; k++ {
+		expected := setup().hierarchicalClustering(k).clusterID
+		got := cutHierarchy(setup().contigs, full.history, k)
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("k = %d: expected clusters %v from the clustering, got %v from the hierarchy", k, expected, got)
+		}
+	}
+}
+
+func TestClusterHistory(t *testing.T) {
+	// Two chains of 4 contigs, which are not linked to each other
+	links := map[[2]int]int64{{0, 1}: 10, {2, 3}: 10, {1, 2}: 5,
+		{4, 5}: 10, {6, 7}: 10, {5, 6}: 4}
+	r := newTestPartitioner(links, 8)
+	r.K = 3
+	r.Cluster()
+	// The history goes on until each chain is a single cluster
+	if len(r.history) != 6 {
+		t.Errorf("Expected 6 merges in the history, got %d", len(r.history))
+	}
+	expected := "[[0 1 2 3] [4 5] [6 7]]"
+	if got := fmt.Sprint(sortedClusters(r.clusters)); got != expected {
+		t.Errorf("Expected clusters %s, got %s", expected, got)
+	}
+}
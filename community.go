@@ -0,0 +1,499 @@
+/*
+ *  community.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"sort"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// weightedEdge is an edge to node `to` in the weighted contig graph
+type weightedEdge struct {
+	to int
+	w  float64
+}
+
+// communityGraph is the undirected weighted graph used by the community
+// detection methods. Self-loops are stored separately, self[i] is the total
+// weight of the edges within node i (non-zero after aggregation).
+type communityGraph struct {
+	adj    [][]weightedEdge
+	self   []float64
+	degree []float64 // Weighted degree, self-loops counted twice
+	m2     float64   // Total degree, i.e. twice the total edge weight
+}
+
+// newCommunityGraph makes a graph from the adjacency lists and self-loops
+func newCommunityGraph(adj [][]weightedEdge, self []float64) *communityGraph {
+	g := &communityGraph{adj: adj, self: self, degree: make([]float64, len(adj))}
+	for i, edges := range adj {
+		g.degree[i] = 2 * self[i]
+		for _, e := range edges {
+			g.degree[i] += e.w
+		}
+		g.m2 += g.degree[i]
+	}
+	return g
+}
+
+// n returns the number of nodes in the graph
+func (g *communityGraph) n() int {
+	return len(g.adj)
+}
+
+// makeCommunityGraph builds the graph of the informative contigs from the
+// normalized link matrix. The matrix is no longer symmetric after
+// skipRepeats(), so the two directions are averaged. Returns the graph and the
+// contig index for each node.
+func (r *Partitioner) makeCommunityGraph() (*communityGraph, []int) {
+	nodes := []int{}
+	for i, contig := range r.contigs {
+		if !contig.skip {
+			nodes = append(nodes, i)
+		}
+	}
+	nodeIdx := make([]int, len(r.contigs))
+	for a, i := range nodes {
+		nodeIdx[i] = a
+	}
+	adj := make([][]weightedEdge, len(nodes))
+	for a, i := range nodes {
+		for _, j := range r.matrix.sortedKeys(i) {
+			if i == j || r.contigs[j].skip {
+				continue
+			}
+			w := float64(r.matrix[i][j]+r.matrix[j][i]) / 2
+			if w > MinAvgLinkage {
+				adj[a] = append(adj[a], weightedEdge{to: nodeIdx[j], w: w})
+			}
+		}
+	}
+	return newCommunityGraph(adj, make([]float64, len(nodes))), nodes
+}
+
+// modularity computes the modularity of the graph with the given community
+// membership, at the given resolution:
+//
+//	Q = Sum_c (2 * in_c / 2m - resolution * (tot_c / 2m) ^ 2)
+//
+// where in_c is the total weight of edges within community c and tot_c is the
+// total degree of the nodes in c.
+func modularity(g *communityGraph, membership []int, resolution float64) float64 {
+	if g.m2 == 0 {
+		return 0
+	}
+	in := map[int]float64{}
+	tot := map[int]float64{}
+	for i, edges := range g.adj {
+		c := membership[i]
+		tot[c] += g.degree[i]
+		in[c] += 2 * g.self[i]
+		for _, e := range edges {
+			if membership[e.to] == c {
+				in[c] += e.w // Each edge is visited twice
+			}
+		}
+	}
+	Q := 0.0
+	for c, t := range tot {
+		Q += in[c]/g.m2 - resolution*(t/g.m2)*(t/g.m2)
+	}
+	return Q
+}
+
+// moveNodes performs the local moving phase of Louvain. Each node is moved to
+// the neighboring community with the largest modularity gain, until no single
+// move improves the modularity. Returns true if any node was moved.
+func moveNodes(g *communityGraph, membership []int, resolution float64) bool {
+	N := g.n()
+	tot := make([]float64, N)
+	for i := 0; i < N; i++ {
+		tot[membership[i]] += g.degree[i]
+	}
+	linksTo := make([]float64, N)
+	neighbors := []int{}
+	moved := false
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < N; i++ {
+			ci := membership[i]
+			ki := g.degree[i]
+			// Links from node i to each neighboring community
+			for _, e := range g.adj[i] {
+				c := membership[e.to]
+				if linksTo[c] == 0 {
+					neighbors = append(neighbors, c)
+				}
+				linksTo[c] += e.w
+			}
+			tot[ci] -= ki
+			best := ci
+			bestGain := linksTo[ci] - resolution*tot[ci]*ki/g.m2
+			for _, c := range neighbors {
+				gain := linksTo[c] - resolution*tot[c]*ki/g.m2
+				if gain > bestGain {
+					best, bestGain = c, gain
+				}
+			}
+			tot[best] += ki
+			for _, c := range neighbors {
+				linksTo[c] = 0
+			}
+			neighbors = neighbors[:0]
+			if best != ci {
+				membership[i] = best
+				improved, moved = true, true
+			}
+		}
+	}
+	return moved
+}
+
+// refineCommunities is the refinement phase of Leiden. Within each community,
+// nodes start as singletons and are greedily merged into the well-connected
+// sub-communities, so that the sub-communities are guaranteed to be connected.
+func refineCommunities(g *communityGraph, membership []int, resolution float64) []int {
+	N := g.n()
+	refined := make([]int, N)
+	totC := make([]float64, N) // Total degree of each community
+	for i := 0; i < N; i++ {
+		refined[i] = i
+		totC[membership[i]] += g.degree[i]
+	}
+	// Total degree of each sub-community and its links to the rest of the community
+	tot := make([]float64, N)
+	external := make([]float64, N)
+	for i := 0; i < N; i++ {
+		tot[i] = g.degree[i]
+		for _, e := range g.adj[i] {
+			if membership[e.to] == membership[i] {
+				external[i] += e.w
+			}
+		}
+	}
+	isSingleton := make([]bool, N)
+	for i := range isSingleton {
+		isSingleton[i] = true
+	}
+
+	linksTo := make([]float64, N)
+	neighbors := []int{}
+	for i := 0; i < N; i++ {
+		c := membership[i]
+		ki := g.degree[i]
+		// Only the singletons that are well connected to their community can move
+		if !isSingleton[i] || external[i] < resolution*ki*(totC[c]-ki)/g.m2 {
+			continue
+		}
+		for _, e := range g.adj[i] {
+			if membership[e.to] != c {
+				continue
+			}
+			s := refined[e.to]
+			if linksTo[s] == 0 {
+				neighbors = append(neighbors, s)
+			}
+			linksTo[s] += e.w
+		}
+		best := refined[i]
+		bestGain := 0.0
+		for _, s := range neighbors {
+			// Only merge into sub-communities well connected to the community
+			if external[s] < resolution*tot[s]*(totC[c]-tot[s])/g.m2 {
+				continue
+			}
+			gain := linksTo[s] - resolution*tot[s]*ki/g.m2
+			if gain > bestGain+EPS {
+				best, bestGain = s, gain
+			}
+		}
+		if best != refined[i] {
+			external[best] += external[i] - 2*linksTo[best]
+			tot[best] += ki
+			tot[i] = 0
+			refined[i] = best
+			isSingleton[best] = false
+			isSingleton[i] = false
+		}
+		for _, s := range neighbors {
+			linksTo[s] = 0
+		}
+		neighbors = neighbors[:0]
+	}
+	return refined
+}
+
+// aggregateGraph collapses the nodes in the same community into a single node.
+// Returns the aggregated graph and the new node ID of each community.
+func aggregateGraph(g *communityGraph, membership []int) (*communityGraph, []int) {
+	newID := make([]int, g.n())
+	for i := range newID {
+		newID[i] = -1
+	}
+	nNodes := 0
+	for i := 0; i < g.n(); i++ {
+		c := membership[i]
+		if newID[c] == -1 {
+			newID[c] = nNodes
+			nNodes++
+		}
+	}
+	weights := make([]map[int]float64, nNodes)
+	for i := range weights {
+		weights[i] = map[int]float64{}
+	}
+	self := make([]float64, nNodes)
+	for i, edges := range g.adj {
+		a := newID[membership[i]]
+		self[a] += g.self[i]
+		for _, e := range edges {
+			b := newID[membership[e.to]]
+			if a == b {
+				if i < e.to {
+					self[a] += e.w
+				}
+			} else {
+				weights[a][b] += e.w
+			}
+		}
+	}
+	adj := make([][]weightedEdge, nNodes)
+	for a, ws := range weights {
+		for b, w := range ws {
+			adj[a] = append(adj[a], weightedEdge{to: b, w: w})
+		}
+		sort.Slice(adj[a], func(i, j int) bool {
+			return adj[a][i].to < adj[a][j].to
+		})
+	}
+	return newCommunityGraph(adj, self), newID
+}
+
+// louvain finds the communities using the Louvain method (Blondel et al. 2008).
+// If refine is set, the Leiden refinement (Traag et al. 2019) is performed
+// before each aggregation. Returns the community of each node.
+func louvain(g *communityGraph, resolution float64, refine bool) []int {
+	nodeToAgg := make([]int, g.n())
+	membership := make([]int, g.n())
+	for i := range membership {
+		nodeToAgg[i] = i
+		membership[i] = i
+	}
+	for level := 1; ; level++ {
+		if !moveNodes(g, membership, resolution) {
+			break
+		}
+		partition := membership
+		if refine {
+			partition = refineCommunities(g, membership, resolution)
+		}
+		aggregated, newID := aggregateGraph(g, partition)
+		// The aggregated nodes start in the communities from the moving phase
+		newMembership := make([]int, aggregated.n())
+		commToAgg := map[int]int{}
+		for i := 0; i < g.n(); i++ {
+			a := newID[partition[i]]
+			if _, ok := commToAgg[membership[i]]; !ok {
+				commToAgg[membership[i]] = a
+			}
+			newMembership[a] = commToAgg[membership[i]]
+		}
+		for i, a := range nodeToAgg {
+			nodeToAgg[i] = newID[partition[a]]
+		}
+		log.Noticef("Level %d: %d nodes aggregated into %d nodes", level, g.n(), aggregated.n())
+		g, membership = aggregated, newMembership
+	}
+	communities := make([]int, len(nodeToAgg))
+	for i, a := range nodeToAgg {
+		communities[i] = membership[a]
+	}
+	return communities
+}
+
+// newman finds the communities by the repeated spectral bisection of the
+// modularity matrix (Newman 2006). Each bisection is refined by flipping the
+// single nodes that increase the modularity, and only accepted if the
+// modularity increases. Returns the community of each node.
+func newman(g *communityGraph, resolution float64) []int {
+	N := g.n()
+	communities := make([]int, N)
+	if N == 0 || g.m2 == 0 {
+		return communities
+	}
+	// B_ij = A_ij - resolution * k_i * k_j / 2m
+	B := Make2DSliceFloat64(N, N)
+	for i := 0; i < N; i++ {
+		for j := 0; j < N; j++ {
+			B[i][j] = -resolution * g.degree[i] * g.degree[j] / g.m2
+		}
+		B[i][i] += 2 * g.self[i]
+		for _, e := range g.adj[i] {
+			B[i][e.to] += e.w
+		}
+	}
+
+	all := make([]int, N)
+	for i := range all {
+		all[i] = i
+	}
+	queue := [][]int{all}
+	nCommunities := 0
+	for len(queue) > 0 {
+		selected := queue[0]
+		queue = queue[1:]
+		partA, partB := bisectModularity(B, selected, g.m2)
+		if len(partA) == 0 || len(partB) == 0 {
+			for _, i := range selected {
+				communities[i] = nCommunities
+			}
+			nCommunities++
+			continue
+		}
+		queue = append(queue, partA, partB)
+	}
+	return communities
+}
+
+// bisectModularity splits the selected nodes into two parts using the leading
+// eigenvector of the generalized modularity matrix:
+//
+//	B(g)_ij = B_ij - d_ij * Sum_k in g B_ik
+//
+// Returns empty parts if the split does not increase the modularity.
+func bisectModularity(B [][]float64, selected []int, m2 float64) ([]int, []int) {
+	n := len(selected)
+	if n < 2 {
+		return nil, nil
+	}
+	Bg := mat64.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		rowSum := 0.0
+		for j := 0; j < n; j++ {
+			rowSum += B[selected[i]][selected[j]]
+			if j >= i {
+				Bg.SetSym(i, j, B[selected[i]][selected[j]])
+			}
+		}
+		Bg.SetSym(i, i, Bg.At(i, i)-rowSum)
+	}
+
+	var (
+		M mat64.Dense
+		e mat64.EigenSym
+	)
+	if !e.Factorize(Bg, true) {
+		return nil, nil
+	}
+	M.EigenvectorsSym(&e)
+	v := M.ColView(n - 1) // Eigenvector corresponding to the largest eigenval
+	s := make([]float64, n)
+	for i := 0; i < n; i++ {
+		s[i] = 1
+		if v.At(i, 0) < 0 {
+			s[i] = -1
+		}
+	}
+
+	// Q = 1/4m s.T * B(g) * s, refined by flipping the node with largest gain
+	Bs := make([]float64, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			Bs[i] += Bg.At(i, j) * s[j]
+		}
+	}
+	Q := 0.0
+	for i := 0; i < n; i++ {
+		Q += s[i] * Bs[i]
+	}
+	Q /= 2 * m2
+	for {
+		best, bestDelta := -1, EPS
+		for i := 0; i < n; i++ {
+			// Flipping s_i changes s.T * B * s by -4 * s_i * (Bs_i - B_ii * s_i)
+			delta := -4 * s[i] * (Bs[i] - Bg.At(i, i)*s[i]) / (2 * m2)
+			if delta > bestDelta {
+				best, bestDelta = i, delta
+			}
+		}
+		if best == -1 {
+			break
+		}
+		s[best] = -s[best]
+		for j := 0; j < n; j++ {
+			Bs[j] += 2 * Bg.At(j, best) * s[best]
+		}
+		Q += bestDelta
+	}
+	if Q <= EPS {
+		return nil, nil
+	}
+
+	var partA, partB []int
+	for i, si := range s {
+		if si > 0 {
+			partA = append(partA, selected[i])
+		} else {
+			partB = append(partB, selected[i])
+		}
+	}
+	return partA, partB
+}
+
+// Community partitions the informative contigs by community detection on the
+// contig graph, as an alternative to the hierarchical clustering. Unlike
+// Cluster(), the number of clusters is determined by the modularity at the
+// given resolution, rather than by K.
+func (r *Partitioner) Community() {
+	g, nodes := r.makeCommunityGraph()
+	log.Noticef("Community detection (method = %s, resolution = %g) starts with %d informative contigs",
+		r.Method, r.Resolution, len(nodes))
+
+	var communities []int
+	switch r.Method {
+	case MethodLouvain:
+		communities = louvain(g, r.Resolution, false)
+	case MethodLeiden:
+		communities = louvain(g, r.Resolution, true)
+	case MethodNewman:
+		communities = newman(g, r.Resolution)
+	default:
+		log.Fatalf("Unknown partition method: %s", r.Method)
+	}
+
+	// Communities are renumbered as new clusters after the contigs, singletons
+	// are left unclustered as in Cluster()
+	sizes := map[int]int{}
+	for _, c := range communities {
+		sizes[c]++
+	}
+	N := len(r.contigs)
+	clusterID := make([]int, N)
+	for i := range clusterID {
+		clusterID[i] = -1
+	}
+	nClusters := 0
+	for a, i := range nodes {
+		clusterID[i] = i
+		if sizes[communities[a]] > 1 {
+			clusterID[i] = N + communities[a]
+		}
+	}
+	for _, size := range sizes {
+		if size > 1 {
+			nClusters++
+		}
+	}
+	log.Noticef("Found %d communities (Q = %.5f)", nClusters, modularity(g, communities, r.Resolution))
+	if r.K > 0 && nClusters != r.K {
+		log.Warningf("Number of communities (%d) differs from k = %d, adjust --resolution to change", nClusters, r.K)
+	}
+	r.setClusters(clusterID)
+}
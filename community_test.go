@@ -0,0 +1,74 @@
+/*
+ *  community_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"math"
+	"testing"
+)
+
+// twoCliques makes two cliques of size n with edges of weight w, bridged by a
+// single edge of weight 1 between the last node of the first clique and the
+// first node of the second clique
+func twoCliques(n int, w float64) *communityGraph {
+	adj := make([][]weightedEdge, 2*n)
+	addEdge := func(i, j int, w float64) {
+		adj[i] = append(adj[i], weightedEdge{to: j, w: w})
+		adj[j] = append(adj[j], weightedEdge{to: i, w: w})
+	}
+	for _, offset := range []int{0, n} {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				addEdge(offset+i, offset+j, w)
+			}
+		}
+	}
+	addEdge(n-1, n, 1)
+	return newCommunityGraph(adj, make([]float64, 2*n))
+}
+
+func TestCommunityTwoCliques(t *testing.T) {
+	n, w := 5, 10.0
+	g := twoCliques(n, w)
+	// Each clique has n(n-1)/2 edges, plus the bridge
+	in := w * float64(n*(n-1)/2)
+	m := 2*in + 1
+	expectedQ := 2 * (in/m - math.Pow((2*in+1)/(2*m), 2))
+
+	tests := []struct {
+		name        string
+		communities []int
+	}{
+		{"louvain", louvain(g, 1, false)},
+		{"leiden", louvain(g, 1, true)},
+		{"newman", newman(g, 1)},
+	}
+	for _, tt := range tests {
+		for i, c := range tt.communities {
+			expected := tt.communities[0]
+			if i >= n {
+				expected = tt.communities[n]
+			}
+			if c != expected {
+				t.Fatalf("%s: expected the two cliques as communities, got %v", tt.name, tt.communities)
+			}
+		}
+		if tt.communities[0] == tt.communities[n] {
+			t.Fatalf("%s: expected two communities, got %v", tt.name, tt.communities)
+		}
+		if Q := modularity(g, tt.communities, 1); math.Abs(Q-expectedQ) > 1e-9 {
+			t.Errorf("%s: expected modularity %.6f, got %.6f", tt.name, expectedQ, Q)
+		}
+	}
+
+	// A single community has zero modularity
+	if Q := modularity(g, make([]int, 2*n), 1); math.Abs(Q) > 1e-9 {
+		t.Errorf("Expected zero modularity of a single community, got %.6f", Q)
+	}
+}
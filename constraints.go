@@ -0,0 +1,297 @@
+/*
+ *  constraints.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a must-link or cannot-link between two contigs. Constraints
+// with weight of 1 (or above) are hard. Soft constraints multiply the merge
+// score by (1 + weight) for must-links, and by (1 - weight) for cannot-links.
+type Constraint struct {
+	a, b     int
+	must     bool
+	weight   float64
+	allelic  bool // Derived from the alleles table rather than the constraints file
+	anchored bool // Derived from the reference anchors
+}
+
+// isHard returns true if the constraint has to be satisfied
+func (c Constraint) isHard() bool {
+	return c.weight >= 1
+}
+
+// logFactor is the log of the multiplier on the merge score for soft constraints
+func (c Constraint) logFactor() float64 {
+	if c.isHard() {
+		return 0
+	}
+	if c.must {
+		return math.Log(1 + c.weight)
+	}
+	return math.Log(1 - c.weight)
+}
+
+// addConstraint adds a constraint between two contigs
+func (r *Partitioner) addConstraint(c Constraint) {
+	r.constraints = append(r.constraints, c)
+	if r.contigConstraints == nil {
+		r.contigConstraints = map[int][]Constraint{}
+	}
+	r.contigConstraints[c.a] = append(r.contigConstraints[c.a], c)
+	r.contigConstraints[c.b] = append(r.contigConstraints[c.b], c)
+}
+
+// readConstraints imports the must-link and cannot-link constraints between
+// contigs, from external evidence such as BACs, genetic or optical maps.
+// File is a tab-separated file that looks like the following, where the weight
+// is optional and defaults to 1 (hard constraint):
+// tig00030660     tig00003333     must
+// tig00038687     tig00038686     cannot  0.5
+func (r *Partitioner) readConstraints() {
+	log.Noticef("Parse constraints file `%s`", r.ConstraintsFile)
+	fh := mustOpen(r.ConstraintsFile)
+	defer fh.Close()
+	reader := bufio.NewReader(fh)
+
+	nMust, nCannot, nUnknown := 0, 0, 0
+	for {
+		row, err := reader.ReadString('\n')
+		row = strings.TrimSpace(row)
+		if row == "" && err == io.EOF {
+			break
+		}
+		if row == "" || row[0] == '#' {
+			continue
+		}
+		words := strings.Fields(row)
+		if len(words) < 3 {
+			log.Fatalf("Malformed line: %s, expecting at least 3 columns", row)
+		}
+		c := Constraint{weight: 1}
+		switch words[2] {
+		case "must":
+			c.must = true
+			nMust++
+		case "cannot":
+			nCannot++
+		default:
+			log.Fatalf("Malformed line: %s, expecting must or cannot", row)
+		}
+		if len(words) > 3 {
+			c.weight, err = strconv.ParseFloat(words[3], 64)
+			if err != nil || c.weight <= 0 {
+				log.Fatalf("Malformed line: %s, expecting positive weight", row)
+			}
+		}
+		a, aok := r.contigToIdx[words[0]]
+		b, bok := r.contigToIdx[words[1]]
+		if !aok || !bok || a == b {
+			nUnknown++
+			continue
+		}
+		c.a, c.b = a, b
+		r.addConstraint(c)
+	}
+	log.Noticef("Loaded %d must-link and %d cannot-link constraints (%d ignored with unknown contigs)",
+		nMust, nCannot, nUnknown)
+}
+
+// setConstraints adds the constraints to the hierarchy. Hard cannot-links are
+// counted between clusters, while soft constraints are summed as the log of
+// the multiplier on the merge score. Constraints on skipped contigs are
+// ignored here and used when the skipped contigs are recovered.
+func (h *hierarchy) setConstraints(constraints []Constraint) {
+	h.conflicts = make([]map[int]int64, 2*h.N)
+	h.softLinks = make([]map[int]float64, 2*h.N)
+	for _, c := range constraints {
+		if h.clusterID[c.a] == -1 || h.clusterID[c.b] == -1 {
+			continue
+		}
+		if c.isHard() {
+			if !c.must {
+				addCount(h.conflicts, c.a, c.b, 1)
+			}
+			continue
+		}
+		addWeight(h.softLinks, c.a, c.b, c.logFactor())
+	}
+}
+
+// addCount adds to the count between a and b in both directions
+func addCount(counts []map[int]int64, a, b int, w int64) {
+	if counts[a] == nil {
+		counts[a] = map[int]int64{}
+	}
+	if counts[b] == nil {
+		counts[b] = map[int]int64{}
+	}
+	counts[a][b] += w
+	counts[b][a] += w
+}
+
+// addWeight adds to the weight between a and b in both directions
+func addWeight(weights []map[int]float64, a, b int, w float64) {
+	if weights[a] == nil {
+		weights[a] = map[int]float64{}
+	}
+	if weights[b] == nil {
+		weights[b] = map[int]float64{}
+	}
+	weights[a][b] += w
+	weights[b][a] += w
+}
+
+// mergeWeights combines the weights between clusters when cluster a and b are
+// merged into newClusterID, similar to mergeCounts()
+func mergeWeights(weights []map[int]float64, a, b, newClusterID int) {
+	large, small := a, b
+	if len(weights[large]) < len(weights[small]) {
+		large, small = small, large
+	}
+	merged := weights[large]
+	if merged == nil {
+		merged = map[int]float64{}
+	}
+	for c, w := range weights[small] {
+		merged[c] += w
+	}
+	delete(merged, a)
+	delete(merged, b)
+	for c := range merged {
+		cWeights := weights[c]
+		if w := cWeights[a] + cWeights[b]; w != 0 {
+			cWeights[newClusterID] = w
+		}
+		delete(cWeights, a)
+		delete(cWeights, b)
+	}
+	weights[newClusterID] = merged
+	weights[a], weights[b] = nil, nil
+}
+
+// forceMustLinks seeds the clusters by merging the contigs connected by hard
+// must-links, before any other merges. Must-links that would merge clusters
+// with hard cannot-links are not forced. Returns the number of forced merges.
+func (h *hierarchy) forceMustLinks(constraints []Constraint) int {
+	nForced := 0
+	for _, c := range constraints {
+		if !c.must || !c.isHard() || h.clusterID[c.a] == -1 || h.clusterID[c.b] == -1 {
+			continue
+		}
+		a, b := h.clusterID[c.a], h.clusterID[c.b]
+		if a == b {
+			continue
+		}
+		if h.conflicts[a][b] > 0 {
+			continue
+		}
+		a, b = min(a, b), max(a, b)
+		h.mergeClusters(a, b)
+		h.history = append(h.history, &merge{a: a, b: b, score: math.Inf(1), forced: true})
+		nForced++
+	}
+	return nForced
+}
+
+// mustLinkCluster returns the cluster that a skipped contig is must-linked to,
+// if the hard must-links all point to the same cluster
+func (r *Partitioner) mustLinkCluster(contigID int, contigToCluster map[int]int) (int, bool) {
+	target := -1
+	for _, c := range r.contigConstraints[contigID] {
+		if !c.must || !c.isHard() {
+			continue
+		}
+		other := c.a
+		if other == contigID {
+			other = c.b
+		}
+		cID, ok := contigToCluster[other]
+		if !ok {
+			continue
+		}
+		if target != -1 && target != cID {
+			return -1, false
+		}
+		target = cID
+	}
+	return target, target != -1
+}
+
+// constrainLinkages removes the clusters that a skipped contig has hard
+// cannot-links to, and applies the soft constraints to the average linkages
+func (r *Partitioner) constrainLinkages(contigID int, linkages []*linkage, contigToCluster map[int]int) []*linkage {
+	constraints, ok := r.contigConstraints[contigID]
+	if !ok {
+		return linkages
+	}
+	blocked := map[int]bool{}
+	logFactors := map[int]float64{}
+	for _, c := range constraints {
+		other := c.a
+		if other == contigID {
+			other = c.b
+		}
+		cID, ok := contigToCluster[other]
+		if !ok {
+			continue
+		}
+		if c.isHard() && !c.must {
+			blocked[cID] = true
+		}
+		logFactors[cID] += c.logFactor()
+	}
+	constrained := []*linkage{}
+	for _, l := range linkages {
+		if blocked[l.cID] {
+			continue
+		}
+		l.avgLinkage *= math.Exp(logFactors[l.cID])
+		constrained = append(constrained, l)
+	}
+	return constrained
+}
+
+// checkConstraints logs the constraints from the constraints file that are not
+// satisfied by the final clusters. A must-link is satisfied if both contigs
+// are in the same cluster, and a cannot-link if they are not.
+func (r *Partitioner) checkConstraints() {
+	contigToCluster := map[int]int{}
+	for cID, cl := range r.clusters {
+		for _, id := range cl {
+			contigToCluster[id] = cID
+		}
+	}
+	nUser, nUnsatisfied := 0, 0
+	for _, c := range r.constraints {
+		if c.allelic || c.anchored {
+			continue
+		}
+		nUser++
+		ca, aok := contigToCluster[c.a]
+		cb, bok := contigToCluster[c.b]
+		together := aok && bok && ca == cb
+		if together == c.must {
+			continue
+		}
+		nUnsatisfied++
+		kind := "cannot"
+		if c.must {
+			kind = "must"
+		}
+		log.Warningf("Constraint not satisfied: %s %s %s (weight = %g)",
+			r.contigs[c.a].name, r.contigs[c.b].name, kind, c.weight)
+	}
+	log.Noticef("%d of %d constraints not satisfied", nUnsatisfied, nUser)
+}
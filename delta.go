@@ -0,0 +1,176 @@
+/*
+ *  delta.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+// tourBlock is a stretch [start, end] of the tour that moves as a unit
+type tourBlock struct {
+	start    int
+	end      int
+	reversed bool
+}
+
+// TourMove rearranges the window [p, q] of the tour into the blocks in order.
+// The contigs outside the window stay in place, and so do the distances
+// between the contigs in the same block.
+type TourMove struct {
+	p      int
+	q      int
+	blocks []tourBlock
+}
+
+// InversionMove reverses the contigs in [p, q]
+func InversionMove(p, q int) TourMove {
+	if p > q {
+		p, q = q, p
+	}
+	return TourMove{p, q, []tourBlock{{p, q, true}}}
+}
+
+// InsertionMove moves k contigs starting at i, so that they start at position
+// `to` in the resulting tour
+func InsertionMove(i, k, to int) TourMove {
+	switch {
+	case to < i:
+		return TourMove{to, i + k - 1, []tourBlock{{i, i + k - 1, false}, {to, i - 1, false}}}
+	case to > i:
+		return TourMove{i, to + k - 1, []tourBlock{{i + k, to + k - 1, false}, {i, i + k - 1, false}}}
+	}
+	return TourMove{i, i - 1, nil}
+}
+
+// SwapMove swaps the contigs at p and q
+func SwapMove(p, q int) TourMove {
+	if p > q {
+		p, q = q, p
+	}
+	if p == q {
+		return TourMove{p, p - 1, nil}
+	}
+	return TourMove{p, q, []tourBlock{{q, q, false}, {p + 1, q - 1, false}, {p, p, false}}}
+}
+
+// TourEvaluator keeps the score of a tour as in Tour.Evaluate(), and computes
+// the score change of a move from the pairs of contigs whose distance changes
+// only. These are the pairs between different blocks, or between a block and
+// the contigs outside the window, that are within LIMIT. For an inversion,
+// only the contigs near the two ends of the window are scored, regardless of
+// the size of the window.
+type TourEvaluator struct {
+	Tour  Tour
+	Score float64
+	mid   []float64
+	end   []int // Last position of the block that each contig is in
+	// Layout of the tour after a move, only the window differs from Tour
+	newTigs []Tig
+	newMid  []float64
+	newEnd  []int
+}
+
+// NewTourEvaluator is the constructor for TourEvaluator. The moves are
+// applied to the tour in place.
+func NewTourEvaluator(tour Tour) *TourEvaluator {
+	n := tour.Len()
+	e := &TourEvaluator{
+		Tour:    tour,
+		mid:     make([]float64, n),
+		end:     make([]int, n),
+		newTigs: make([]Tig, n),
+		newMid:  make([]float64, n),
+		newEnd:  make([]int, n),
+	}
+	cumSum := 0.0
+	for i, t := range tour.Tigs {
+		tsize := float64(t.Size)
+		e.mid[i] = cumSum + tsize/2
+		cumSum += tsize
+	}
+	copy(e.newTigs, tour.Tigs)
+	copy(e.newMid, e.mid)
+	e.Score, _ = tour.Evaluate()
+	return e
+}
+
+// layout writes the window of the tour after the move into newTigs and newMid
+func (e *TourEvaluator) layout(m TourMove) {
+	if m.p > m.q {
+		return
+	}
+	tigs := e.Tour.Tigs
+	pos := m.p
+	offset := e.mid[m.p] - float64(tigs[m.p].Size)/2
+	for _, b := range m.blocks {
+		start := pos
+		for k := b.start; k <= b.end; k++ {
+			t := tigs[k]
+			if b.reversed {
+				t = tigs[b.start+b.end-k]
+			}
+			tsize := float64(t.Size)
+			e.newTigs[pos] = t
+			e.newMid[pos] = offset + tsize/2
+			offset += tsize
+			e.end[k] = b.end
+			pos++
+		}
+		for k := start; k < pos; k++ {
+			e.newEnd[k] = pos - 1
+		}
+	}
+}
+
+// windowScore sums the scores of the pairs with at least one contig in the
+// window [p, q], skipping the pairs within the same block
+func (e *TourEvaluator) windowScore(tigs []Tig, mid []float64, end []int, p, q int) float64 {
+	n := len(tigs)
+	M := e.Tour.M
+	score := 0.0
+	for i := p; i <= q; i++ {
+		a := tigs[i].Idx
+		for j := end[i] + 1; j < n; j++ {
+			dist := mid[j] - mid[i]
+			if dist > LIMIT {
+				break
+			}
+			score -= float64(M[a][tigs[j].Idx]) / dist
+		}
+		for j := p - 1; j >= 0; j-- {
+			dist := mid[i] - mid[j]
+			if dist > LIMIT {
+				break
+			}
+			score -= float64(M[a][tigs[j].Idx]) / dist
+		}
+	}
+	return score
+}
+
+// Delta returns the score change if the move is applied
+func (e *TourEvaluator) Delta(m TourMove) float64 {
+	if m.p > m.q {
+		return 0
+	}
+	e.layout(m)
+	oldScore := e.windowScore(e.Tour.Tigs, e.mid, e.end, m.p, m.q)
+	newScore := e.windowScore(e.newTigs, e.newMid, e.newEnd, m.p, m.q)
+	// Restore the layout to the current tour
+	copy(e.newTigs[m.p:m.q+1], e.Tour.Tigs[m.p:m.q+1])
+	copy(e.newMid[m.p:m.q+1], e.mid[m.p:m.q+1])
+	return newScore - oldScore
+}
+
+// Apply applies the move to the tour, with the score change from Delta()
+func (e *TourEvaluator) Apply(m TourMove, delta float64) {
+	if m.p > m.q {
+		return
+	}
+	e.layout(m)
+	copy(e.Tour.Tigs[m.p:m.q+1], e.newTigs[m.p:m.q+1])
+	copy(e.mid[m.p:m.q+1], e.newMid[m.p:m.q+1])
+	e.Score += delta
+}
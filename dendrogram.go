@@ -0,0 +1,123 @@
+/*
+ *  dendrogram.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// nodeName returns the name of a cluster in the hierarchy, contigs are named
+// as is and the cluster created by the k-th merge is named mk
+func (r *Partitioner) nodeName(cID int) string {
+	N := len(r.contigs)
+	if cID < N {
+		return r.contigs[cID].name
+	}
+	return fmt.Sprintf("m%d", cID-N+1)
+}
+
+// writeMergeHistory writes the full sequence of merges in the hierarchical
+// clustering, so that the hierarchy can be cut at a different level. Each row
+// lists the two merged clusters, the new cluster, its size, the average linkage
+// and the number of non-singleton clusters after the merge.
+func (r *Partitioner) writeMergeHistory() {
+	outfile := RemoveExt(RemoveExt(r.PairsFile)) + ".merges.txt"
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	N := len(r.contigs)
+	size := make([]int, N+len(r.history))
+	for i := 0; i < N; i++ {
+		size[i] = 1
+	}
+	nonSingletonClusters := 0
+	fmt.Fprintf(w, MergeHistoryHeader)
+	for k, m := range r.history {
+		size[N+k] = size[m.a] + size[m.b]
+		if m.a < N {
+			nonSingletonClusters++
+		}
+		if m.b < N {
+			nonSingletonClusters++
+		}
+		nonSingletonClusters--
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%.6g\t%d\n", k+1, r.nodeName(m.a), r.nodeName(m.b),
+			r.nodeName(N+k), size[N+k], m.score, nonSingletonClusters)
+	}
+	w.Flush()
+	log.Noticef("A total of %d merges written to `%s`", len(r.history), outfile)
+}
+
+// writeDendrogram writes the hierarchy in Newick format, one tree per line for
+// each cluster at the top of the hierarchy. The height of a merge is the
+// inverse of its average linkage, so strongly linked contigs join near the
+// leaves. Merges forced by must-links have a height of 0.
+func (r *Partitioner) writeDendrogram() {
+	outfile := RemoveExt(RemoveExt(r.PairsFile)) + ".dendrogram.nwk"
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	N := len(r.contigs)
+	nNodes := N + len(r.history)
+	height := make([]float64, nNodes)
+	hasParent := make([]bool, nNodes)
+	for k, m := range r.history {
+		if m.score > 0 && !math.IsInf(m.score, 1) {
+			height[N+k] = 1 / m.score
+		}
+		height[N+k] = math.Max(height[N+k], math.Max(height[m.a], height[m.b]))
+		hasParent[m.a] = true
+		hasParent[m.b] = true
+	}
+
+	var writeNode func(sb *strings.Builder, cID int, parentHeight float64)
+	writeNode = func(sb *strings.Builder, cID int, parentHeight float64) {
+		if cID >= N {
+			m := r.history[cID-N]
+			sb.WriteString("(")
+			writeNode(sb, m.a, height[cID])
+			sb.WriteString(",")
+			writeNode(sb, m.b, height[cID])
+			sb.WriteString(")")
+		}
+		sb.WriteString(newickName(r.nodeName(cID)))
+		if parentHeight >= 0 {
+			fmt.Fprintf(sb, ":%.6g", parentHeight-height[cID])
+		}
+	}
+
+	nTrees := 0
+	for cID := N; cID < nNodes; cID++ {
+		if hasParent[cID] {
+			continue
+		}
+		sb := &strings.Builder{}
+		writeNode(sb, cID, -1)
+		fmt.Fprintf(w, "%s;\n", sb.String())
+		nTrees++
+	}
+	w.Flush()
+	log.Noticef("Dendrogram with %d trees written to `%s`", nTrees, outfile)
+}
+
+// newickName quotes the names with characters that are special in Newick
+func newickName(name string) string {
+	if strings.ContainsAny(name, " ()[]',:;") {
+		return "'" + strings.Replace(name, "'", "''", -1) + "'"
+	}
+	return name
+}
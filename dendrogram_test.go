@@ -0,0 +1,40 @@
+/*
+ *  dendrogram_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDendrogram(t *testing.T) {
+	r := newTestPartitioner(nil, 6)
+	r.contigs[3].name = "tig:3"
+	r.PairsFile = filepath.Join(t.TempDir(), "test.pairs.txt")
+	r.history = []*merge{
+		{a: 0, b: 1, score: 10},
+		{a: 2, b: 3, score: 4},
+		{a: 4, b: 5, score: math.Inf(1), forced: true},
+		{a: 6, b: 7, score: 2},
+	}
+	r.writeDendrogram()
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Dir(r.PairsFile), "test.dendrogram.nwk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One tree for each cluster at the top, in the order of the merges. The
+	// heights are 1 / linkage, and the forced merge has a height of 0.
+	expected := "(tig4:0,tig5:0)m3;\n" +
+		"((tig0:0.1,tig1:0.1)m1:0.4,(tig2:0.25,'tig:3':0.25)m2:0.25)m4;\n"
+	if string(data) != expected {
+		t.Errorf("Expected dendrogram\n%s\ngot\n%s", expected, data)
+	}
+}
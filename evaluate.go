@@ -49,17 +49,17 @@ func (r Tour) Swap(i, j int) {
 
 // Slice method from Slice
 func (r Tour) Slice(a, b int) eaopt.Slice {
-	return Tour{r.Tigs[a:b], r.M}
+	return Tour{r.Tigs[a:b], r.M, r.crossover}
 }
 
 // Split method from Slice
 func (r Tour) Split(k int) (eaopt.Slice, eaopt.Slice) {
-	return Tour{r.Tigs[:k], r.M}, Tour{r.Tigs[k:], r.M}
+	return Tour{r.Tigs[:k], r.M, r.crossover}, Tour{r.Tigs[k:], r.M, r.crossover}
 }
 
 // Append method from Slice
 func (r Tour) Append(q eaopt.Slice) eaopt.Slice {
-	return Tour{append(r.Tigs, q.(Tour).Tigs...), r.M}
+	return Tour{append(r.Tigs, q.(Tour).Tigs...), r.M, r.crossover}
 }
 
 // Replace method from Slice
@@ -73,6 +73,7 @@ func (r Tour) Copy() eaopt.Slice {
 	clone.Tigs = make([]Tig, r.Len())
 	copy(clone.Tigs, r.Tigs)
 	clone.M = r.M
+	clone.crossover = r.crossover
 	return clone
 }
 
@@ -247,9 +248,100 @@ func (r Tour) Mutate(rng *rand.Rand) {
 	}
 }
 
-// Crossover a Tour with another Tour by using Partially Mixed Crossover (PMX).
+// CrossERX applies Edge Recombination Crossover (ERX) and writes the offspring
+// into p1. The offspring is built from the adjacencies found in either parent,
+// always moving to the neighbor with the fewest remaining neighbors. Unlike
+// eaopt.CrossERX, ties are broken by rng, so the results are reproducible
+// given the seed. Tours are linear, so the ends are not joined.
+func CrossERX(p1, p2 eaopt.Slice, rng *rand.Rand) {
+	n := p1.Len()
+	genes := make([]interface{}, n)
+	geneToIdx := map[interface{}]int{}
+	for i := 0; i < n; i++ {
+		genes[i] = p1.At(i)
+		geneToIdx[genes[i]] = i
+	}
+	// Adjacency lists from both parents, without duplicates
+	neighbors := make([][]int, n)
+	addEdge := func(a, b int) {
+		for _, c := range neighbors[a] {
+			if c == b {
+				return
+			}
+		}
+		neighbors[a] = append(neighbors[a], b)
+		neighbors[b] = append(neighbors[b], a)
+	}
+	for _, p := range []eaopt.Slice{p1, p2} {
+		for i := 1; i < n; i++ {
+			addEdge(geneToIdx[p.At(i-1)], geneToIdx[p.At(i)])
+		}
+	}
+
+	visited := make([]bool, n)
+	degree := func(a int) int {
+		d := 0
+		for _, c := range neighbors[a] {
+			if !visited[c] {
+				d++
+			}
+		}
+		return d
+	}
+	offspring := make([]int, 0, n)
+	current := 0
+	for {
+		visited[current] = true
+		offspring = append(offspring, current)
+		if len(offspring) == n {
+			break
+		}
+		// Next is the unvisited neighbor with the fewest unvisited neighbors
+		candidates := []int{}
+		minDegree := n
+		for _, c := range neighbors[current] {
+			if visited[c] {
+				continue
+			}
+			d := degree(c)
+			if d < minDegree {
+				candidates, minDegree = []int{c}, d
+			} else if d == minDegree {
+				candidates = append(candidates, c)
+			}
+		}
+		// Dead end, restart from any unvisited gene
+		if len(candidates) == 0 {
+			for i := 0; i < n; i++ {
+				if !visited[i] {
+					candidates = append(candidates, i)
+				}
+			}
+		}
+		current = candidates[rng.Intn(len(candidates))]
+	}
+	for i, a := range offspring {
+		p1.Set(i, genes[a])
+	}
+}
+
+// Crossover a Tour with another Tour by using one of the order-preserving
+// operators: Partially Mapped Crossover (PMX), Ordered Crossover (OX) or Edge
+// Recombination Crossover (ERX). eaopt passes the mate by value and would keep
+// its fitness even if modified, so only r takes the offspring.
 func (r Tour) Crossover(q eaopt.Genome, rng *rand.Rand) {
-	//eaopt.CrossPMX(r, q.(Tour), rng)
+	if r.Len() < 3 {
+		return
+	}
+	mate := q.(Tour).Copy()
+	switch r.crossover {
+	case CrossoverPMX:
+		eaopt.CrossPMX(r, mate, rng)
+	case CrossoverOX:
+		eaopt.CrossOX(r, mate, rng)
+	case CrossoverERX:
+		CrossERX(r, mate, rng)
+	}
 }
 
 // Clone a Tour
@@ -258,6 +350,7 @@ func (r Tour) Clone() eaopt.Genome {
 	clone.Tigs = make([]Tig, r.Len())
 	copy(clone.Tigs, r.Tigs)
 	clone.M = r.M
+	clone.crossover = r.crossover
 	return clone
 }
 
@@ -274,10 +367,24 @@ func (r Tour) Shuffle(rng *rand.Rand) {
 // GARun set up the Genetic Algorithm and run it
 func (r *CLM) GARun(fwtour *os.File, opt *Optimizer, phase int) Tour {
 	MakeTour := func(rng *rand.Rand) eaopt.Genome {
-		c := r.Tour.Clone()
+		c := r.Tour.Clone().(Tour)
+		c.crossover = opt.Crossover
 		return c
 	}
+	printTour := func(g eaopt.Genome, label string) {
+		r.printTour(fwtour, g.(Tour), label)
+	}
 
+	r.Tour = runGA(opt, phase, "GA", MakeTour, printTour).(Tour)
+	return r.Tour
+}
+
+// runGA runs the Genetic Algorithm on the genomes from makeGenome, until the
+// best score has not improved for NGen generations. The best genome is logged
+// with printGenome every 500 generations. The best score and the generation it
+// is found are kept in opt.BestScore and opt.BestGeneration.
+func runGA(opt *Optimizer, phase int, name string, makeGenome func(rng *rand.Rand) eaopt.Genome,
+	printGenome func(g eaopt.Genome, label string)) eaopt.Genome {
 	ga, err := eaopt.NewDefaultGAConfig().NewGA()
 	if err != nil {
 		panic(err)
@@ -290,7 +397,8 @@ func (r *CLM) GARun(fwtour *os.File, opt *Optimizer, phase int) Tour {
 		Selector: eaopt.SelTournament{
 			NContestants: 3,
 		},
-		MutRate: opt.MutProb,
+		MutRate:   opt.MutProb,
+		CrossRate: opt.CrossProb,
 	}
 	ga.RNG = opt.rng
 	ga.ParallelEval = true
@@ -309,11 +417,10 @@ func (r *CLM) GARun(fwtour *os.File, opt *Optimizer, phase int) Tour {
 			*updated = gen
 		}
 		if gen%500 == 0 {
-			fmt.Printf("Current iteration GA%d-%d: max_score=%.5f\n",
-				phase, gen, currentBest)
-			currentBestTour := ga.HallOfFame[0].Genome.(Tour)
-			r.printTour(fwtour, currentBestTour, fmt.Sprintf("GA%d-%d-%.5f",
-				phase, gen, currentBest))
+			fmt.Printf("Current iteration %s%d-%d: max_score=%.5f\n",
+				name, phase, gen, currentBest)
+			printGenome(ga.HallOfFame[0].Genome, fmt.Sprintf("%s%d-%d-%.5f",
+				name, phase, gen, currentBest))
 		}
 	}
 
@@ -322,11 +429,13 @@ func (r *CLM) GARun(fwtour *os.File, opt *Optimizer, phase int) Tour {
 		return ga.Generations-*updated > uint(opt.NGen)
 	}
 
-	log.Noticef("GA initialized (npop: %v, ngen: %v, mu: %.2f, rng: %d, break: %d)",
-		opt.NPop, opt.NGen, opt.MutProb, opt.Seed, LIMIT)
+	log.Noticef("%s initialized (npop: %v, ngen: %v, mu: %.2f, crossover: %s, cx: %.2f, rng: %d, break: %d)",
+		name, opt.NPop, opt.NGen, opt.MutProb, opt.Crossover, opt.CrossProb, opt.Seed, LIMIT)
 
-	ga.Minimize(MakeTour)
+	ga.Minimize(makeGenome)
+	log.Noticef("%s%d stopped at generation %d, best score %.5f found at generation %d",
+		name, phase, ga.Generations, *best, *updated)
+	opt.BestScore, opt.BestGeneration = *best, *updated
 
-	r.Tour = ga.HallOfFame[0].Genome.(Tour)
-	return r.Tour
+	return ga.HallOfFame[0].Genome
 }
@@ -0,0 +1,201 @@
+/*
+ *  evaluate_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic_test
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/MaxHalford/eaopt"
+	"github.com/tanghaibao/allhic"
+)
+
+func TestCrossERX(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	n := 20
+	for trial := 0; trial < 100; trial++ {
+		p1, p2 := eaopt.IntSlice(rng.Perm(n)), eaopt.IntSlice(rng.Perm(n))
+		edges := map[[2]int]bool{}
+		for _, p := range []eaopt.IntSlice{p1, p2} {
+			for i := 1; i < n; i++ {
+				edges[[2]int{p[i-1], p[i]}] = true
+				edges[[2]int{p[i], p[i-1]}] = true
+			}
+		}
+		offspring := eaopt.IntSlice(append([]int{}, p1...))
+		allhic.CrossERX(offspring, p2, rng)
+
+		seen := map[int]bool{}
+		nParentEdges := 0
+		for i, a := range offspring {
+			if seen[a] {
+				t.Fatalf("Gene %d repeated in offspring %v", a, offspring)
+			}
+			seen[a] = true
+			if i > 0 && edges[[2]int{offspring[i-1], a}] {
+				nParentEdges++
+			}
+		}
+		if len(seen) != n {
+			t.Fatalf("Expected %d genes in offspring, got %d", n, len(seen))
+		}
+		// Most adjacencies should be inherited from the parents
+		if nParentEdges < n/2 {
+			t.Errorf("Only %d of %d adjacencies inherited in offspring %v", nParentEdges, n-1, offspring)
+		}
+	}
+}
+
+// closeEnough checks if the delta matches the change of the full evaluation
+func closeEnough(delta, before, after float64) bool {
+	return math.Abs(delta-(after-before)) <= 1e-9*math.Max(1, math.Abs(before))
+}
+
+// checkTourEvaluator applies random moves to the tour and compares the deltas
+// against the full evaluation
+func checkTourEvaluator(t *testing.T, tour allhic.Tour, rng *rand.Rand) {
+	e := allhic.NewTourEvaluator(tour)
+	n := e.Tour.Len()
+	for trial := 0; trial < 1000; trial++ {
+		p, q := rng.Intn(n), rng.Intn(n)
+		var name string
+		var m allhic.TourMove
+		switch trial % 4 {
+		case 0:
+			name, m = "inversion", allhic.InversionMove(p, q)
+		case 1:
+			name, m = "insertion", allhic.InsertionMove(p, 1, q)
+		case 2:
+			k := 1 + rng.Intn(3)
+			name, m = "segment insertion", allhic.InsertionMove(rng.Intn(n-k+1), k, rng.Intn(n-k+1))
+		case 3:
+			name, m = "swap", allhic.SwapMove(p, q)
+		}
+		before, _ := e.Tour.Evaluate()
+		delta := e.Delta(m)
+		e.Apply(m, delta)
+		after, _ := e.Tour.Evaluate()
+		if !closeEnough(delta, before, after) {
+			t.Fatalf("Delta of %s %d, %d is %g, full evaluation gives %g", name, p, q, delta, after-before)
+		}
+	}
+	score, _ := e.Tour.Evaluate()
+	if !closeEnough(0, score, e.Score) {
+		t.Errorf("Score after the moves is %g, full evaluation gives %g", e.Score, score)
+	}
+}
+
+// randomTour makes a tour of n contigs with random sizes and links
+func randomTour(n, minSize, maxSize int, rng *rand.Rand) allhic.Tour {
+	tour := allhic.Tour{Tigs: make([]allhic.Tig, n), M: make([][]int, n)}
+	for i := range tour.Tigs {
+		tour.Tigs[i] = allhic.Tig{Idx: i, Size: minSize + rng.Intn(maxSize-minSize)}
+		tour.M[i] = make([]int, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			tour.M[i][j] = rng.Intn(100)
+			tour.M[j][i] = tour.M[i][j]
+		}
+	}
+	return tour
+}
+
+func TestTourEvaluatorDelta(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	clm := allhic.NewCLM(path.Join("tests", "simulation", "test.clm"), path.Join("tests", "simulation", "test.ids"))
+	clm.Activate(true, rng)
+	checkTourEvaluator(t, clm.Tour.Clone().(allhic.Tour), rng)
+
+	// Long tour where most pairs are beyond LIMIT
+	tour := randomTour(300, 10000, 1000000, rng)
+	checkTourEvaluator(t, tour, rng)
+}
+
+func TestDeltaFlip(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	clm := allhic.NewCLM(path.Join("tests", "simulation", "test.clm"), path.Join("tests", "simulation", "test.ids"))
+	clm.Activate(true, rng)
+	for i, tig := range clm.Tour.Tigs {
+		before := clm.EvaluateQ()
+		delta := clm.DeltaFlip(i)
+		sign := clm.Signs[tig.Idx]
+		clm.Signs[tig.Idx] = map[byte]byte{'+': '-', '-': '+'}[sign]
+		after := clm.EvaluateQ()
+		if !closeEnough(delta, before, after) {
+			t.Fatalf("Delta of flipping %d is %g, full evaluation gives %g", i, delta, after-before)
+		}
+	}
+}
+
+// benchmarkCrossover runs optimize on the simulated group of 100 contigs, and
+// reports the best score and the generation it is found, averaged over the seeds
+func benchmarkCrossover(b *testing.B, crossover string) {
+	dir, err := ioutil.TempDir("", "allhic")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, file := range []string{"test.ids", "test.clm"} {
+		data, err := ioutil.ReadFile(path.Join("tests", "simulation", file))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, file), data, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	nSeeds := 3
+	score, gens := 0.0, 0.0
+	for i := 0; i < b.N; i++ {
+		for seed := 0; seed < nSeeds; seed++ {
+			p := allhic.Optimizer{REfile: path.Join(dir, "test.ids"), Clmfile: path.Join(dir, "test.clm"),
+				RunGA: true, Seed: int64(i*nSeeds + seed), NPop: allhic.Npop, NGen: 500,
+				MutProb: allhic.MutaProb, Crossover: crossover, CrossProb: .5}
+			p.Run()
+			score += p.BestScore
+			gens += float64(p.BestGeneration)
+		}
+	}
+	runs := float64(b.N * nSeeds)
+	b.ReportMetric(score/runs, "score")
+	b.ReportMetric(gens/runs, "gens")
+}
+
+func BenchmarkCrossoverNone(b *testing.B) { benchmarkCrossover(b, allhic.CrossoverNone) }
+func BenchmarkCrossoverPMX(b *testing.B)  { benchmarkCrossover(b, allhic.CrossoverPMX) }
+func BenchmarkCrossoverOX(b *testing.B)   { benchmarkCrossover(b, allhic.CrossoverOX) }
+func BenchmarkCrossoverERX(b *testing.B)  { benchmarkCrossover(b, allhic.CrossoverERX) }
+
+// Score changes of the random inversions in a group of 2000 contigs, by full
+// evaluation or by the deltas
+func BenchmarkEvaluateInversion(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	tour := randomTour(2000, 10000, 100000, rng)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := tour.Clone().(allhic.Tour)
+		allhic.MutInversion(c, rng)
+		c.Evaluate()
+	}
+}
+
+func BenchmarkDeltaInversion(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	e := allhic.NewTourEvaluator(randomTour(2000, 10000, 100000, rng))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p, q := rng.Intn(e.Tour.Len()), rng.Intn(e.Tour.Len())
+		e.Delta(allhic.InversionMove(p, q))
+	}
+}
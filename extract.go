@@ -46,6 +46,7 @@ type Extracter struct {
 	OutContigsfile string
 	OutPairsfile   string
 	OutClmfile     string
+	OutCisfile     string
 }
 
 // ContigInfo stores results calculated from f
@@ -57,6 +58,10 @@ type ContigInfo struct {
 	nExpectedLinks float64
 	nObservedLinks int
 	skip           bool
+	cisLinks       int     // intra-contig links, read from the cis.txt in partition
+	transLinks     int     // inter-contig links
+	linkFactor     float64 // number of Hi-C links relative to the average contig in partition
+	skipReason     string  // SHORT or REPETITIVE if skipped in partition
 }
 
 // ContigPair stores results calculated from findDistanceBetweenContigs
@@ -230,9 +235,6 @@ func CountPattern(seq []byte, pattern Pattern) int {
 func (r *Extracter) readFastaAndWriteRE() {
 	outfile := RemoveExt(r.Bamfile) + ".counts_" + strings.ReplaceAll(r.RE, ",", "_") + ".txt"
 	r.OutContigsfile = outfile
-	mustExist(r.Fastafile)
-	reader, _ := fastx.NewDefaultReader(r.Fastafile)
-	seq.ValidateSeq = false // This flag makes parsing FASTA much faster
 
 	r.contigs = []*ContigInfo{}
 	r.contigToIdx = map[string]int{}
@@ -240,18 +242,10 @@ func (r *Extracter) readFastaAndWriteRE() {
 	totalBp := int64(0)
 	pattern := MakePattern(r.RE)
 
-	for {
-		rec, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-
-		name := string(rec.Name)
-		// Strip the sequence name to get the first part up to empty space
-		name = strings.Fields(name)[0]
+	readFasta(r.Fastafile, func(name string, s []byte) {
 		// Add pseudo-count of 1 to prevent division by zero
-		count := CountPattern(rec.Seq.Seq, pattern) + 1
-		length := rec.Seq.Length()
+		count := CountPattern(s, pattern) + 1
+		length := len(s)
 		totalCounts += count
 		totalBp += int64(length)
 		contig := &ContigInfo{
@@ -262,10 +256,30 @@ func (r *Extracter) readFastaAndWriteRE() {
 
 		r.contigToIdx[name] = len(r.contigs)
 		r.contigs = append(r.contigs, contig)
-	}
+	})
 	writeRE(outfile, r.contigs)
 }
 
+// readFasta streams through the FASTA file and calls fn on each record, so the
+// sequences are never all held in memory at the same time
+func readFasta(fastafile string, fn func(name string, s []byte)) {
+	mustExist(fastafile)
+	reader, _ := fastx.NewDefaultReader(fastafile)
+	seq.ValidateSeq = false // This flag makes parsing FASTA much faster
+
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		ErrorAbort(err)
+
+		// Strip the sequence name to get the first part up to empty space
+		name := strings.Fields(string(rec.Name))[0]
+		fn(name, rec.Seq.Seq)
+	}
+}
+
 // calcIntraContigs determine the local enrichment of links on this contig.
 func (r *Extracter) calcIntraContigs() {
 	for _, contig := range r.contigs {
@@ -535,4 +549,26 @@ func (r *Extracter) extractContigLinks() {
 	wclm.Flush()
 	log.Noticef("Extracted %d inter-contig groups to `%s` (total = %d, maxLinks = %d, minLinks = %d)",
 		len(contigPairs), clmfile, total, maxLinks, r.MinLinks)
+
+	for pair, links := range contigPairs {
+		r.contigs[pair[0]].transLinks += len(links)
+		r.contigs[pair[1]].transLinks += len(links)
+	}
+	r.OutCisfile = prefix + ".cis.txt"
+	writeCisTrans(r.OutCisfile, r.contigs)
+}
+
+// writeCisTrans writes the number of intra-contig (cis) and inter-contig
+// (trans) links of each contig, used to classify the contigs in partition
+func writeCisTrans(outfile string, contigs []*ContigInfo) {
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+	fmt.Fprintf(w, CisTransHeader)
+	for _, contig := range contigs {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", contig.name, len(contig.links), contig.transLinks)
+	}
+	w.Flush()
+	log.Noticef("Cis and trans links of %d contigs written to `%s`", len(contigs), outfile)
 }
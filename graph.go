@@ -0,0 +1,180 @@
+/*
+ *  graph.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// graphNode holds the attributes of a contig in the exported graph
+type graphNode struct {
+	name       string
+	length     int
+	recounts   int
+	cluster    string
+	skipReason string
+}
+
+// clone returns a copy of the link matrix
+func (M LinkMatrix) clone() LinkMatrix {
+	C := NewLinkMatrix(len(M))
+	for i := range M {
+		for j, w := range M[i] {
+			C[i][j] = w
+		}
+	}
+	return C
+}
+
+// exportGraph writes the contig link graph, with the normalized links from
+// makeMatrix() as the edge weights and the contig length, RE counts, cluster
+// and skip reason as the node attributes. The format is chosen by the file
+// extension: .graphml, .gexf or .dot (.gv), to be viewed in Gephi, Cytoscape
+// or Graphviz.
+func (r *Partitioner) exportGraph(M LinkMatrix) {
+	contigToCluster := map[int]int{}
+	for cID, cl := range r.clusters {
+		for _, id := range cl {
+			contigToCluster[id] = cID
+		}
+	}
+	nodes := make([]graphNode, len(r.contigs))
+	for i, contig := range r.contigs {
+		nodes[i] = graphNode{name: contig.name, length: contig.length, recounts: contig.recounts,
+			cluster: "-", skipReason: contig.skipReason}
+		if cID, ok := contigToCluster[i]; ok {
+			nodes[i].cluster = r.clusterLabel(cID)
+		}
+	}
+
+	writeGraph := graphWriter(r.ExportGraph)
+	if writeGraph == nil {
+		log.Fatalf("Unknown graph format `%s`, expecting .graphml, .gexf or .dot", r.ExportGraph)
+	}
+	f, err := os.Create(r.ExportGraph)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	nEdges := writeGraph(w, nodes, M)
+	w.Flush()
+	log.Noticef("Graph with %d nodes and %d edges written to `%s`", len(nodes), nEdges, r.ExportGraph)
+}
+
+// graphWriter returns the writer of the graph format given by the file
+// extension, or nil if the format is unknown
+func graphWriter(filename string) func(io.Writer, []graphNode, LinkMatrix) int {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".graphml":
+		return writeGraphML
+	case ".gexf":
+		return writeGEXF
+	case ".dot", ".gv":
+		return writeDOT
+	}
+	return nil
+}
+
+// forEachEdge calls fn on each edge i < j in the link matrix in sorted order
+func forEachEdge(M LinkMatrix, fn func(i, j int, weight int64)) int {
+	nEdges := 0
+	for i := range M {
+		for _, j := range M.sortedKeys(i) {
+			if j > i {
+				fn(i, j, M[i][j])
+				nEdges++
+			}
+		}
+	}
+	return nEdges
+}
+
+// xmlEscape escapes the special characters in XML text and attributes
+func xmlEscape(s string) string {
+	sb := &strings.Builder{}
+	xml.EscapeText(sb, []byte(s))
+	return sb.String()
+}
+
+// writeGraphML writes the graph in GraphML
+func writeGraphML(w io.Writer, nodes []graphNode, M LinkMatrix) int {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(w, `  <key id="label" for="node" attr.name="label" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <key id="length" for="node" attr.name="length" attr.type="int"/>`)
+	fmt.Fprintln(w, `  <key id="recounts" for="node" attr.name="recounts" attr.type="int"/>`)
+	fmt.Fprintln(w, `  <key id="cluster" for="node" attr.name="cluster" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <key id="skip" for="node" attr.name="skip" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <key id="weight" for="edge" attr.name="weight" attr.type="long"/>`)
+	fmt.Fprintln(w, `  <graph id="G" edgedefault="undirected">`)
+	for i, n := range nodes {
+		fmt.Fprintf(w, `    <node id="n%d"><data key="label">%s</data>`, i, xmlEscape(n.name))
+		fmt.Fprintf(w, `<data key="length">%d</data><data key="recounts">%d</data>`, n.length, n.recounts)
+		fmt.Fprintf(w, `<data key="cluster">%s</data><data key="skip">%s</data></node>`+"\n",
+			xmlEscape(n.cluster), n.skipReason)
+	}
+	nEdges := forEachEdge(M, func(i, j int, weight int64) {
+		fmt.Fprintf(w, `    <edge source="n%d" target="n%d"><data key="weight">%d</data></edge>`+"\n",
+			i, j, weight)
+	})
+	fmt.Fprintln(w, `  </graph>`)
+	fmt.Fprintln(w, `</graphml>`)
+	return nEdges
+}
+
+// writeGEXF writes the graph in GEXF 1.2 as used by Gephi
+func writeGEXF(w io.Writer, nodes []graphNode, M LinkMatrix) int {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<gexf xmlns="http://www.gexf.net/1.2draft" version="1.2">`)
+	fmt.Fprintln(w, `  <graph defaultedgetype="undirected">`)
+	fmt.Fprintln(w, `    <attributes class="node">`)
+	fmt.Fprintln(w, `      <attribute id="0" title="length" type="integer"/>`)
+	fmt.Fprintln(w, `      <attribute id="1" title="recounts" type="integer"/>`)
+	fmt.Fprintln(w, `      <attribute id="2" title="cluster" type="string"/>`)
+	fmt.Fprintln(w, `      <attribute id="3" title="skip" type="string"/>`)
+	fmt.Fprintln(w, `    </attributes>`)
+	fmt.Fprintln(w, `    <nodes>`)
+	for i, n := range nodes {
+		fmt.Fprintf(w, `      <node id="%d" label="%s"><attvalues>`, i, xmlEscape(n.name))
+		fmt.Fprintf(w, `<attvalue for="0" value="%d"/><attvalue for="1" value="%d"/>`, n.length, n.recounts)
+		fmt.Fprintf(w, `<attvalue for="2" value="%s"/><attvalue for="3" value="%s"/>`, xmlEscape(n.cluster), n.skipReason)
+		fmt.Fprintln(w, `</attvalues></node>`)
+	}
+	fmt.Fprintln(w, `    </nodes>`)
+	fmt.Fprintln(w, `    <edges>`)
+	nEdges := 0
+	forEachEdge(M, func(i, j int, weight int64) {
+		fmt.Fprintf(w, `      <edge id="%d" source="%d" target="%d" weight="%d"/>`+"\n", nEdges, i, j, weight)
+		nEdges++
+	})
+	fmt.Fprintln(w, `    </edges>`)
+	fmt.Fprintln(w, `  </graph>`)
+	fmt.Fprintln(w, `</gexf>`)
+	return nEdges
+}
+
+// writeDOT writes the graph in the DOT language of Graphviz
+func writeDOT(w io.Writer, nodes []graphNode, M LinkMatrix) int {
+	fmt.Fprintln(w, "graph allhic {")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "  %q [length=%d, recounts=%d, cluster=%q, skip=%q];\n",
+			n.name, n.length, n.recounts, n.cluster, n.skipReason)
+	}
+	nEdges := forEachEdge(M, func(i, j int, weight int64) {
+		fmt.Fprintf(w, "  %q -- %q [weight=%d];\n", nodes[i].name, nodes[j].name, weight)
+	})
+	fmt.Fprintln(w, "}")
+	return nEdges
+}
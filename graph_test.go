@@ -0,0 +1,47 @@
+/*
+ *  graph_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportGraph(t *testing.T) {
+	for _, filename := range []string{"g.graphml", "g.GEXF", "g.dot", "g.gv"} {
+		if graphWriter(filename) == nil {
+			t.Errorf("Expected a writer for %s", filename)
+		}
+	}
+	if graphWriter("g.txt") != nil {
+		t.Errorf("Expected no writer for g.txt")
+	}
+
+	r := newTestPartitioner(map[[2]int]int64{{0, 1}: 10, {1, 2}: 5}, 3)
+	r.K = 1
+	r.clusters = Clusters{0: {0, 1}}
+	r.contigs[2].skipReason = "SHORT"
+	r.ExportGraph = filepath.Join(t.TempDir(), "test.dot")
+	r.exportGraph(r.matrix)
+	data, err := ioutil.ReadFile(r.ExportGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "graph allhic {\n" +
+		"  \"tig0\" [length=100000, recounts=100, cluster=\"1g1\", skip=\"\"];\n" +
+		"  \"tig1\" [length=100000, recounts=100, cluster=\"1g1\", skip=\"\"];\n" +
+		"  \"tig2\" [length=100000, recounts=100, cluster=\"-\", skip=\"SHORT\"];\n" +
+		"  \"tig0\" -- \"tig1\" [weight=10];\n" +
+		"  \"tig1\" -- \"tig2\" [weight=5];\n" +
+		"}\n"
+	if string(data) != expected {
+		t.Errorf("Expected graph\n%s\ngot\n%s", expected, data)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ *  joint.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/MaxHalford/eaopt"
+)
+
+// OrientedTig is a contig in the tour along with its orientation
+type OrientedTig struct {
+	Tig
+	Sign byte
+}
+
+// OrientedTour carries the orientations of the contigs along with the order,
+// so that the GA searches the order and orientations jointly
+type OrientedTour struct {
+	Tigs      []OrientedTig
+	clm       *CLM
+	crossover string
+}
+
+// At method from Slice
+func (r OrientedTour) At(i int) interface{} {
+	return r.Tigs[i]
+}
+
+// Set method from Slice
+func (r OrientedTour) Set(i int, v interface{}) {
+	r.Tigs[i] = v.(OrientedTig)
+}
+
+// Len method from Slice
+func (r OrientedTour) Len() int {
+	return len(r.Tigs)
+}
+
+// Swap method from Slice
+func (r OrientedTour) Swap(i, j int) {
+	r.Tigs[i], r.Tigs[j] = r.Tigs[j], r.Tigs[i]
+}
+
+// Slice method from Slice
+func (r OrientedTour) Slice(a, b int) eaopt.Slice {
+	return OrientedTour{r.Tigs[a:b], r.clm, r.crossover}
+}
+
+// Split method from Slice
+func (r OrientedTour) Split(k int) (eaopt.Slice, eaopt.Slice) {
+	return OrientedTour{r.Tigs[:k], r.clm, r.crossover}, OrientedTour{r.Tigs[k:], r.clm, r.crossover}
+}
+
+// Append method from Slice
+func (r OrientedTour) Append(q eaopt.Slice) eaopt.Slice {
+	return OrientedTour{append(r.Tigs, q.(OrientedTour).Tigs...), r.clm, r.crossover}
+}
+
+// Replace method from Slice
+func (r OrientedTour) Replace(q eaopt.Slice) {
+	copy(r.Tigs, q.(OrientedTour).Tigs)
+}
+
+// Copy method from Slice
+func (r OrientedTour) Copy() eaopt.Slice {
+	return r.Clone().(OrientedTour)
+}
+
+// Clone an OrientedTour
+func (r OrientedTour) Clone() eaopt.Genome {
+	var clone OrientedTour
+	clone.Tigs = make([]OrientedTig, r.Len())
+	copy(clone.Tigs, r.Tigs)
+	clone.clm = r.clm
+	clone.crossover = r.crossover
+	return clone
+}
+
+// Evaluate calculates the score with the orientation-aware link distances,
+// same as CLM.EvaluateQ() but with the orientations carried in the tour
+func (r OrientedTour) Evaluate() (float64, error) {
+	size := r.Len()
+	cumsize := make([]int, size)
+	cumSum := 0
+	for i, t := range r.Tigs {
+		cumsize[i] = cumSum
+		cumSum += t.Size
+	}
+
+	score := 0.0
+	for i := 0; i < size; i++ {
+		a := r.Tigs[i]
+		for j := i + 1; j < size; j++ {
+			dist := cumsize[j-1] - cumsize[i]
+			if dist > LIMIT {
+				break
+			}
+			b := r.Tigs[j]
+			score += r.clm.pairScoreQ(a.Idx, b.Idx, a.Sign, b.Sign, dist)
+		}
+	}
+	// eaopt looks for the minimum
+	return -score, nil
+}
+
+// MutFlip flips the orientation of a single contig
+func MutFlip(genome OrientedTour, rng *rand.Rand) {
+	i := rng.Intn(genome.Len())
+	genome.Tigs[i].Sign = rr(genome.Tigs[i].Sign)
+}
+
+// MutBlockFlip reverses a block of contigs and flips their orientations, as
+// if the block is inverted in the scaffold
+func MutBlockFlip(genome OrientedTour, rng *rand.Rand) {
+	p, q := randomTwoInts(genome, rng)
+	for i, j := p, q; i < j; i, j = i+1, j-1 {
+		genome.Swap(i, j)
+	}
+	for i := p; i <= q; i++ {
+		genome.Tigs[i].Sign = rr(genome.Tigs[i].Sign)
+	}
+}
+
+// Mutate an OrientedTour by changing the order, the orientation of a single
+// contig, or both for a block of contigs
+func (r OrientedTour) Mutate(rng *rand.Rand) {
+	rd := rng.Float64()
+	if rd < .15 {
+		MutPermute(r, rng)
+	} else if rd < .3 {
+		MutSplice(r, rng)
+	} else if rd < .5 {
+		MutFlip(r, rng)
+	} else if rd < .75 {
+		MutInsertion(r, rng)
+	} else {
+		MutBlockFlip(r, rng)
+	}
+}
+
+// Crossover recombines the order of the contigs using the crossover of Tour,
+// and the contigs keep their orientations in r
+func (r OrientedTour) Crossover(q eaopt.Genome, rng *rand.Rand) {
+	p1, p2 := r.tour(), q.(OrientedTour).tour()
+	p1.crossover = r.crossover
+	p1.Crossover(p2, rng)
+
+	signs := map[int]byte{}
+	for _, t := range r.Tigs {
+		signs[t.Idx] = t.Sign
+	}
+	for i, t := range p1.Tigs {
+		r.Tigs[i] = OrientedTig{t, signs[t.Idx]}
+	}
+}
+
+// tour returns the contigs in the OrientedTour as a Tour
+func (r OrientedTour) tour() Tour {
+	tigs := make([]Tig, r.Len())
+	for i, t := range r.Tigs {
+		tigs[i] = t.Tig
+	}
+	return Tour{Tigs: tigs}
+}
+
+// JointGARun runs the Genetic Algorithm on the order and orientations of the
+// contigs jointly, starting from the current tour and orientations
+func (r *CLM) JointGARun(fwtour *os.File, opt *Optimizer, phase int) Tour {
+	MakeTour := func(rng *rand.Rand) eaopt.Genome {
+		c := OrientedTour{Tigs: make([]OrientedTig, r.Tour.Len()), clm: r, crossover: opt.Crossover}
+		for i, t := range r.Tour.Tigs {
+			c.Tigs[i] = OrientedTig{t, r.Signs[t.Idx]}
+		}
+		return c
+	}
+	printTour := func(g eaopt.Genome, label string) {
+		r.printOrientedTour(fwtour, g.(OrientedTour), label)
+	}
+
+	best := runGA(opt, phase, "JointGA", MakeTour, printTour).(OrientedTour)
+	for i, t := range best.Tigs {
+		r.Tour.Tigs[i] = t.Tig
+		r.Signs[t.Idx] = t.Sign
+	}
+	return r.Tour
+}
+
+// printOrientedTour logs the current tour with its own orientations
+func (r *CLM) printOrientedTour(fwtour *os.File, tour OrientedTour, label string) {
+	fwtour.WriteString(">" + label + "\n")
+	atoms := make([]string, tour.Len())
+	for i, t := range tour.Tigs {
+		atoms[i] = r.Tigs[t.Idx].Name + string(t.Sign)
+	}
+	fwtour.WriteString(strings.Join(atoms, " ") + "\n")
+}
@@ -0,0 +1,106 @@
+/*
+ *  joint_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"math"
+	"math/rand"
+	"path"
+	"testing"
+)
+
+// randomOrientedTour puts the contigs of the CLM in a random order, each with a
+// random orientation
+func randomOrientedTour(clm *CLM, rng *rand.Rand) OrientedTour {
+	tour := OrientedTour{Tigs: make([]OrientedTig, clm.Tour.Len()), clm: clm}
+	for i, j := range rng.Perm(clm.Tour.Len()) {
+		tour.Tigs[i] = OrientedTig{clm.Tour.Tigs[j], "+-"[rng.Intn(2)]}
+	}
+	return tour
+}
+
+func TestOrientedTourEvaluate(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	clm := NewCLM(path.Join("tests", "simulation", "test.clm"), path.Join("tests", "simulation", "test.ids"))
+	clm.Activate(true, rng)
+	for trial := 0; trial < 10; trial++ {
+		tour := randomOrientedTour(clm, rng)
+		for i, tig := range tour.Tigs {
+			clm.Tour.Tigs[i] = tig.Tig
+			clm.Signs[tig.Idx] = tig.Sign
+		}
+		score, _ := tour.Evaluate()
+		expected := -clm.EvaluateQ()
+		if math.Abs(score-expected) > 1e-9*math.Max(1, math.Abs(expected)) {
+			t.Fatalf("Expected score %g from EvaluateQ, got %g", expected, score)
+		}
+	}
+}
+
+func TestMutBlockFlip(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	clm := NewCLM(path.Join("tests", "simulation", "test.clm"), path.Join("tests", "simulation", "test.ids"))
+	clm.Activate(true, rng)
+	for seed := int64(0); seed < 20; seed++ {
+		before := randomOrientedTour(clm, rng)
+		after := before.Clone().(OrientedTour)
+		MutBlockFlip(after, rand.New(rand.NewSource(seed)))
+		// The same block as picked in MutBlockFlip
+		p, q := randomTwoInts(before, rand.New(rand.NewSource(seed)))
+		for i, tig := range after.Tigs {
+			expected := before.Tigs[i]
+			if i >= p && i <= q {
+				expected = before.Tigs[p+q-i]
+				expected.Sign = rr(expected.Sign)
+			}
+			if tig != expected {
+				t.Fatalf("Block %d-%d: expected %v at %d, got %v", p, q, expected, i, tig)
+			}
+		}
+	}
+}
+
+func TestOrientedTourCrossover(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	clm := NewCLM(path.Join("tests", "simulation", "test.clm"), path.Join("tests", "simulation", "test.ids"))
+	clm.Activate(true, rng)
+	for _, crossover := range []string{CrossoverPMX, CrossoverOX, CrossoverERX} {
+		nChanged := 0
+		for trial := 0; trial < 20; trial++ {
+			p1, p2 := randomOrientedTour(clm, rng), randomOrientedTour(clm, rng)
+			p1.crossover = crossover
+			signs := map[int]byte{}
+			for _, t := range p1.Tigs {
+				signs[t.Idx] = t.Sign
+			}
+			offspring := p1.Clone().(OrientedTour)
+			offspring.Crossover(p2, rng)
+
+			seen := map[int]bool{}
+			for i, tig := range offspring.Tigs {
+				if seen[tig.Idx] {
+					t.Fatalf("%s: contig %d repeated in offspring", crossover, tig.Idx)
+				}
+				seen[tig.Idx] = true
+				if tig.Sign != signs[tig.Idx] {
+					t.Fatalf("%s: contig %d expected to keep sign %c, got %c", crossover, tig.Idx, signs[tig.Idx], tig.Sign)
+				}
+				if tig.Idx != p1.Tigs[i].Idx {
+					nChanged++
+				}
+			}
+			if len(seen) != p1.Len() {
+				t.Fatalf("%s: expected %d contigs in offspring, got %d", crossover, p1.Len(), len(seen))
+			}
+		}
+		if nChanged == 0 {
+			t.Errorf("%s: expected the order to change in crossover", crossover)
+		}
+	}
+}
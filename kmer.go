@@ -0,0 +1,292 @@
+/*
+ *  kmer.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Sketch is a FracMinHash sketch of a contig, i.e. the sorted hashes of all
+// canonical k-mers that fall below the max hash
+type Sketch struct {
+	Name   string
+	Length int
+	Hashes []uint64
+}
+
+// AllelicPair stores the k-mer similarity between two contigs, where a is the
+// longer and b is the shorter contig
+type AllelicPair struct {
+	a, b        int
+	shared      int
+	containment float64 // Fraction of hashes in b that are also found in a
+	jaccard     float64
+}
+
+// baseCodes maps the nucleotides to 2-bit codes, anything else is -1
+var baseCodes = func() [256]int8 {
+	var codes [256]int8
+	for i := range codes {
+		codes[i] = -1
+	}
+	for _, c := range "Aa" {
+		codes[c] = 0
+	}
+	for _, c := range "Cc" {
+		codes[c] = 1
+	}
+	for _, c := range "Gg" {
+		codes[c] = 2
+	}
+	for _, c := range "Tt" {
+		codes[c] = 3
+	}
+	return codes
+}()
+
+// mixHash is the finalizer of splitmix64, which scrambles the 2-bit encoded
+// k-mers so that the hashes are uniformly distributed
+func mixHash(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// SketchSequence computes the FracMinHash sketch of a sequence. Only the
+// canonical k-mers (k <= 32) whose hashes are below maxHash are retained.
+func SketchSequence(s []byte, k int, maxHash uint64) []uint64 {
+	mask := uint64(1)<<(2*uint(k)) - 1
+	if k == 32 {
+		mask = math.MaxUint64
+	}
+	shift := 2 * uint(k-1)
+	var fw, rc uint64
+	seen := map[uint64]bool{}
+	l := 0 // Number of valid bases in the current k-mer
+	for _, c := range s {
+		code := baseCodes[c]
+		if code < 0 {
+			l = 0
+			continue
+		}
+		fw = (fw<<2 | uint64(code)) & mask
+		rc = rc>>2 | uint64(3-code)<<shift
+		l++
+		if l < k {
+			continue
+		}
+		kmer := fw
+		if rc < fw {
+			kmer = rc
+		}
+		if h := mixHash(kmer); h < maxHash {
+			seen[h] = true
+		}
+	}
+
+	hashes := make([]uint64, 0, len(seen))
+	for h := range seen {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+	return hashes
+}
+
+// sketchContigs computes sketches for all contigs in the FASTA file. Only the
+// sketches are kept in memory, which is roughly genome size / Scale hashes.
+func (r *Alleler) sketchContigs() []Sketch {
+	log.Noticef("Sketch contigs in `%s` (k = %d, scale = %d)", r.Fastafile, r.K, r.Scale)
+	maxHash := uint64(math.MaxUint64) / uint64(r.Scale)
+	sketches := []Sketch{}
+	totalHashes := 0
+	readFasta(r.Fastafile, func(name string, s []byte) {
+		hashes := SketchSequence(s, r.K, maxHash)
+		totalHashes += len(hashes)
+		sketches = append(sketches, Sketch{Name: name, Length: len(s), Hashes: hashes})
+	})
+	log.Noticef("Sketched %d contigs (total hashes = %d)", len(sketches), totalHashes)
+	return sketches
+}
+
+// findAllelicPairs computes the containment between all pairs of contigs
+// that share hashes. Hashes that occur in more than MaxOccurrence contigs are
+// likely from repeats and ignored, which also keeps the pair search tractable.
+// The containment and Jaccard are computed over the remaining hashes.
+func (r *Alleler) findAllelicPairs(sketches []Sketch) []AllelicPair {
+	// Inverted index from hash to contigs
+	index := map[uint64][]int32{}
+	for i, sketch := range sketches {
+		for _, h := range sketch.Hashes {
+			index[h] = append(index[h], int32(i))
+		}
+	}
+	nRepeats := 0
+	for h, ids := range index {
+		if len(ids) > r.MaxOccurrence {
+			delete(index, h)
+			nRepeats++
+		}
+	}
+	log.Noticef("Ignored %d of %d hashes found in > %d contigs",
+		nRepeats, nRepeats+len(index), r.MaxOccurrence)
+	// Number of hashes left in each sketch after the repeats are removed
+	nHashes := make([]int, len(sketches))
+	for _, ids := range index {
+		for _, i := range ids {
+			nHashes[i]++
+		}
+	}
+
+	// Count the shared hashes for each contig against the rest, the counters
+	// are reused so the memory is linear in the number of contigs
+	N := len(sketches)
+	counts := make([]int32, N)
+	touched := []int{}
+	pairs := []AllelicPair{}
+	for i, sketch := range sketches {
+		for _, h := range sketch.Hashes {
+			for _, j := range index[h] {
+				if int(j) <= i {
+					continue
+				}
+				if counts[j] == 0 {
+					touched = append(touched, int(j))
+				}
+				counts[j]++
+			}
+		}
+		for _, j := range touched {
+			shared := int(counts[j])
+			counts[j] = 0
+			a, b := i, j
+			if sketches[a].Length < sketches[b].Length {
+				a, b = b, a
+			}
+			na, nb := nHashes[a], nHashes[b]
+			// Skip pairs of incompatible sizes or too few hashes to be reliable
+			if nb < r.MinHashes ||
+				float64(sketches[b].Length) < r.MinLengthRatio*float64(sketches[a].Length) {
+				continue
+			}
+			containment := float64(shared) / float64(min(na, nb))
+			if containment < r.MinContainment {
+				continue
+			}
+			pairs = append(pairs, AllelicPair{
+				a: a, b: b,
+				shared:      shared,
+				containment: containment,
+				jaccard:     float64(shared) / float64(na+nb-shared),
+			})
+		}
+		touched = touched[:0]
+	}
+	log.Noticef("Found %d allelic pairs (MinContainment = %.2f)", len(pairs), r.MinContainment)
+	return pairs
+}
+
+// groupAllelesByKmers builds the allele groups around the longer contigs, each
+// group contains the longer contig and all shorter contigs contained in it.
+// Groups that are a subset of an earlier group are redundant and skipped.
+func groupAllelesByKmers(sketches []Sketch, pairs []AllelicPair) []AlleleRow {
+	partners := map[int][]AllelicPair{}
+	for _, pair := range pairs {
+		partners[pair.a] = append(partners[pair.a], pair)
+	}
+	primaries := make([]int, 0, len(partners))
+	for a := range partners {
+		primaries = append(primaries, a)
+	}
+	sort.Slice(primaries, func(i, j int) bool {
+		li, lj := sketches[primaries[i]].Length, sketches[primaries[j]].Length
+		return li > lj || (li == lj && primaries[i] < primaries[j])
+	})
+
+	rows := []AlleleRow{}
+	ctgToRows := map[int][]int{}
+	for _, a := range primaries {
+		group := []int{a}
+		for _, pair := range partners[a] {
+			group = append(group, pair.b)
+		}
+		if isSubsetOfRows(group, ctgToRows) {
+			continue
+		}
+		contigs := make([]string, len(group))
+		for i, id := range group {
+			contigs[i] = sketches[id].Name
+			ctgToRows[id] = append(ctgToRows[id], len(rows))
+		}
+		// The k-mer groups have no reference locus, so the groups are numbered
+		// along a pseudo-chromosome instead
+		rows = append(rows, AlleleRow{
+			Chrom:   KmerAllelesChrom,
+			Pos:     len(rows) + 1,
+			Contigs: contigs,
+		})
+	}
+	return rows
+}
+
+// isSubsetOfRows checks if all contigs in the group are found in the same row
+func isSubsetOfRows(group []int, ctgToRows map[int][]int) bool {
+	rowCounts := map[int]int{}
+	for _, id := range group {
+		for _, row := range ctgToRows[id] {
+			rowCounts[row]++
+			if rowCounts[row] == len(group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeAllelicPairs writes the k-mer similarity scores between contig pairs
+func writeAllelicPairs(outfile string, sketches []Sketch, pairs []AllelicPair) {
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	fmt.Fprintf(w, AllelicPairsHeader)
+	for _, pair := range pairs {
+		a, b := sketches[pair.a], sketches[pair.b]
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.4f\t%.4f\n",
+			a.Name, b.Name, a.Length, b.Length, pair.shared, pair.containment, pair.jaccard)
+	}
+	w.Flush()
+	log.Noticef("A total of %d allelic pairs written to `%s`", len(pairs), outfile)
+}
+
+// runKmer builds the allele table by alignment-free comparison of the contigs,
+// using the containment of FracMinHash sketches
+func (r *Alleler) runKmer() {
+	if r.K < 1 || r.K > 32 {
+		log.Fatalf("K-mer size needs to be between 1 and 32, got %d", r.K)
+	}
+	sketches := r.sketchContigs()
+	pairs := r.findAllelicPairs(sketches)
+	r.Alleles = groupAllelesByKmers(sketches, pairs)
+	if r.OutAllelesFile == "" {
+		r.OutAllelesFile = RemoveExt(strings.TrimSuffix(r.Fastafile, ".gz")) + ".alleles.table"
+	}
+	writeAllelicPairs(RemoveExt(r.OutAllelesFile)+".pairs.txt", sketches, pairs)
+	writeAllelesTable(r.OutAllelesFile, r.Alleles)
+}
@@ -29,13 +29,42 @@ type Optimizer struct {
 	NGen      int
 	MutProb   float64
 	CrossProb float64
+	Crossover string // none, pmx, ox or erx
+	Method    string // ga, sa, localsearch or hybrid
+	Joint     bool   // GA on order and orientations jointly
 	rng       *rand.Rand
 	// Output files
 	OutTourFile string
+	// Results of the last GA run
+	BestScore      float64
+	BestGeneration uint
 }
 
 // Run kicks off the Optimizer
 func (r *Optimizer) Run() {
+	switch r.Method {
+	case MethodGA, MethodSA, MethodLocalSearch, MethodHybrid:
+	case "":
+		r.Method = MethodGA
+	default:
+		log.Fatalf("Unknown optimize method: %s, expecting %s, %s, %s or %s",
+			r.Method, MethodGA, MethodSA, MethodLocalSearch, MethodHybrid)
+	}
+	if !r.RunGA && r.Method != MethodGA {
+		log.Fatalf("Skipping GA also skips the ordering, which is searched with method %s", r.Method)
+	}
+	// The local search in hybrid is blind to the orientations
+	if r.Joint && r.Method != MethodGA {
+		log.Fatalf("Joint search runs with method %s, not %s", MethodGA, r.Method)
+	}
+	switch r.Crossover {
+	case CrossoverNone, CrossoverPMX, CrossoverOX, CrossoverERX:
+	case "":
+		r.Crossover = CrossoverNone
+	default:
+		log.Fatalf("Unknown crossover: %s, expecting %s, %s, %s or %s",
+			r.Crossover, CrossoverNone, CrossoverPMX, CrossoverOX, CrossoverERX)
+	}
 	r.rng = rand.New(rand.NewSource(r.Seed))
 	clm := NewCLM(r.Clmfile, r.REfile)
 	tourfile := RemoveExt(r.REfile) + ".tour"
@@ -79,9 +108,25 @@ func (r *Optimizer) Run() {
 	log.Notice("Success")
 }
 
-// OptimizeOrdering changes the ordering of contigs by Genetic Algorithm
+// OptimizeOrdering changes the ordering of contigs by Genetic Algorithm,
+// simulated annealing, local search, or GA followed by local search. With
+// Joint, GA changes the orientations of contigs along with the ordering.
 func (r *CLM) OptimizeOrdering(fwtour *os.File, opt *Optimizer, phase int) {
-	r.GARun(fwtour, opt, phase)
+	switch opt.Method {
+	case MethodGA:
+		if opt.Joint {
+			r.JointGARun(fwtour, opt, phase)
+		} else {
+			r.GARun(fwtour, opt, phase)
+		}
+	case MethodSA:
+		r.SARun(fwtour, opt, phase)
+	case MethodLocalSearch:
+		r.LocalSearch(fwtour, phase)
+	case MethodHybrid:
+		r.GARun(fwtour, opt, phase)
+		r.LocalSearch(fwtour, phase)
+	}
 	// r.pruneTour()
 }
 
@@ -151,11 +196,10 @@ func (r *CLM) parseTourFile(filename string) {
 
 // parseClustersFile parses clusters file
 func (r *CLM) parseClustersFile(clustersfile string, group int) {
-	recs := ReadCSVLines(clustersfile)
+	_, groups := readClustersFile(clustersfile)
 	r.prepareTour()
 
-	rec := recs[group]
-	names := strings.Split(rec[2], " ")
+	names := groups[group]
 	tigs := []Tig{}
 	for _, tigName := range names {
 		idx, ok := r.tigToIdx[tigName]
@@ -88,16 +88,16 @@ func (r *CLM) flipOne() (tag string) {
 	nAccepts := 0
 	nRejects := 0
 	anyTagACCEPT := false
+	cumsize := r.tourCumSizes()
 	score := r.EvaluateQ()
 	for i, t := range r.Tour.Tigs {
 		idx := t.Idx
-		r.Signs[idx] = rr(r.Signs[idx])
-		newScore := r.EvaluateQ()
+		newScore := score + r.deltaFlip(cumsize, i)
 		if newScore > score {
+			r.Signs[idx] = rr(r.Signs[idx])
 			nAccepts++
 			tag = ACCEPT
 		} else {
-			r.Signs[idx] = rr(r.Signs[idx]) // Recover
 			nRejects++
 			tag = REJECT
 		}
@@ -152,42 +152,86 @@ func (r *CLM) Q() [][]GArray {
 	return P
 }
 
+// tourCumSizes returns the total size of the contigs before each position of
+// the tour
+func (r *CLM) tourCumSizes() []int {
+	cumsize := make([]int, r.Tour.Len())
+	cumSum := 0
+	for i, t := range r.Tour.Tigs {
+		cumsize[i] = cumSum
+		cumSum += t.Size
+	}
+	return cumsize
+}
+
+// pairScoreQ returns the score of the links between contig a and b, given
+// their orientations and the distance between them
+func (r *CLM) pairScoreQ(a, b int, ao, bo byte, dist int) float64 {
+	gdists, ok := r.orientedContacts[OrientedPair{a, b, ao, bo}]
+	if !ok {
+		return 0
+	}
+	score := 0.0
+	for k := 0; k < BB; k++ {
+		if gdists[k] != 0 {
+			score -= float64(gdists[k]) * math.Log(float64(GR[k]+dist))
+		}
+	}
+	return score
+}
+
 // EvaluateQ sums up all distance is defined as the sizes of interleaving contigs
 // plus the actual link distances. Maximize Sum(1 / distance) for all links.
 // For performance consideration, we actually use a histogram to approximate
 // all link distances. See goldenArray() for details.
 func (r *CLM) EvaluateQ() float64 {
 	tour := r.Tour
-	Q := r.Q()
+	cumsize := r.tourCumSizes()
 	score := 0.0
 
-	size := tour.Len()
-	cumsize := make([]int, size)
-	cumSum := 0
-	for i, t := range tour.Tigs {
-		cumsize[i] = cumSum
-		cumSum += t.Size
-	}
-	// fmt.Println(tour.Tigs, cumsize)
-
 	// Now add up all the pairwise scores
+	size := tour.Len()
 	for i := 0; i < size; i++ {
 		a := tour.Tigs[i].Idx
 		for j := i + 1; j < size; j++ {
-			b := tour.Tigs[j].Idx
-			if Q[a][b][0] == -1 {
-				continue // Entire GArray is empty
-			}
 			dist := cumsize[j-1] - cumsize[i]
 			if dist > LIMIT {
 				break
 			}
-			for k := 0; k < BB; k++ {
-				// score += float64(Q[a][b][k]) / float64(GR[k]+dist)
-				score -= float64(Q[a][b][k]) * math.Log(float64(GR[k]+dist))
-			}
-			// fmt.Println(r.Tigs[a], r.Tigs[b], Q[a][b], score)
+			b := tour.Tigs[j].Idx
+			score += r.pairScoreQ(a, b, r.Signs[a], r.Signs[b], dist)
 		}
 	}
 	return score
 }
+
+// DeltaFlip returns the change of EvaluateQ() if the contig at position i of
+// the tour is flipped, computed from the pairs with that contig only
+func (r *CLM) DeltaFlip(i int) float64 {
+	return r.deltaFlip(r.tourCumSizes(), i)
+}
+
+// deltaFlip is DeltaFlip with the cumulative sizes of the tour precomputed
+func (r *CLM) deltaFlip(cumsize []int, i int) float64 {
+	tour := r.Tour
+	a := tour.Tigs[i].Idx
+	ao, flipped := r.Signs[a], rr(r.Signs[a])
+	delta := 0.0
+	for j := i + 1; j < tour.Len(); j++ {
+		dist := cumsize[j-1] - cumsize[i]
+		if dist > LIMIT {
+			break
+		}
+		b := tour.Tigs[j].Idx
+		delta += r.pairScoreQ(a, b, flipped, r.Signs[b], dist) - r.pairScoreQ(a, b, ao, r.Signs[b], dist)
+	}
+	for j := i - 1; j >= 0; j-- {
+		dist := cumsize[i-1] - cumsize[j]
+		if dist > LIMIT {
+			break
+		}
+		b := tour.Tigs[j].Idx
+		delta += r.pairScoreQ(b, a, r.Signs[b], flipped, dist) - r.pairScoreQ(b, a, r.Signs[b], ao, dist)
+	}
+	return delta
+}
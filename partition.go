@@ -12,7 +12,9 @@ package allhic
 import (
 	"fmt"
 	"math"
+	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -24,7 +26,7 @@ type Partitioner struct {
 	K           int
 	contigs     []*ContigInfo
 	contigToIdx map[string]int
-	matrix      [][]int64
+	matrix      LinkMatrix
 	longestRE   int
 	clusters    Clusters
 	// Output files
@@ -33,24 +35,139 @@ type Partitioner struct {
 	MinREs              int
 	MaxLinkDensity      int
 	NonInformativeRatio int
+	Method              string  // One of ahc, louvain, leiden, newman
+	Resolution          float64 // Resolution in modularity for community detection
+	AutoK               bool    // Choose k automatically between MinK and MaxK
+	MinK                int
+	MaxK                int
+	AllelesFile         string  // Optional, allelic contigs are not clustered together
+	AllelicPenalty      float64 // Penalty on merges between allelic contigs, 1 to reject
+	ConstraintsFile     string  // Optional, must-link and cannot-link constraints
+	constraints         []Constraint
+	contigConstraints   map[int][]Constraint
+	Ploidy              int    // Split each homologous group into Ploidy haplotypes if > 1
+	HomologPairsFile    string // Unpruned pairs to find the homologous groups
+	labels              []string
+	history             []*merge     // Merges in the hierarchical clustering
+	recovered           map[int]bool // Skipped contigs recovered into clusters
+	AnchorsFile         string       // Optional, PAF of the contigs against a related reference
+	anchors             map[int]string
+	referenceChrs       []string
+	FromClusters        string // Optional, take the clusters from (edited) clusters.txt
+	GroupSizes          []int  // Optional, expected sizes of the groups in bp
+	GenomeSize          int    // Optional, expected genome size in bp, divided evenly into k groups
+	CisFile             string // Optional, cis and trans links per contig from extract
+	ExportGraph         string // Optional, write the link graph in GraphML, GEXF or DOT
+	hasCisTrans         bool
+}
+
+// LinkMatrix is a sparse matrix of normalized link counts, where row i maps
+// the contigs linked to contig i to the link score. Missing entries are 0, so
+// the entries can be read as matrix[i][j] like a dense matrix.
+type LinkMatrix []map[int]int64
+
+// NewLinkMatrix allocates an empty LinkMatrix for N contigs
+func NewLinkMatrix(N int) LinkMatrix {
+	M := make(LinkMatrix, N)
+	for i := range M {
+		M[i] = map[int]int64{}
+	}
+	return M
+}
+
+// sortedKeys returns the contigs linked to contig i in ascending order
+func (M LinkMatrix) sortedKeys(i int) []int {
+	keys := make([]int, 0, len(M[i]))
+	for j := range M[i] {
+		keys = append(keys, j)
+	}
+	sort.Ints(keys)
+	return keys
 }
 
 // Run is the main function body of partition
 func (r *Partitioner) Run() {
+	if r.AllelicPenalty < 0 || r.AllelicPenalty > 1 {
+		log.Fatalf("Invalid allelic penalty: %g, expecting 0 to 1", r.AllelicPenalty)
+	}
+	if r.ExportGraph != "" && graphWriter(r.ExportGraph) == nil {
+		log.Fatalf("Unknown graph format `%s`, expecting .graphml, .gexf or .dot", r.ExportGraph)
+	}
 	r.readRE()
+	if r.CisFile == "" {
+		// extract writes sample.cis.txt next to sample.pairs.txt
+		cisFile := RemoveExt(RemoveExt(r.PairsFile)) + ".cis.txt"
+		if _, err := os.Stat(cisFile); err == nil {
+			r.CisFile = cisFile
+		}
+	}
+	if r.CisFile != "" {
+		r.readCisTrans()
+	}
 	r.skipContigsWithFewREs()
 	// if r.K == 1 {
 	// 	r.makeTrivialClusters()
 	// } else {
 	r.makeMatrix()
+	var graph LinkMatrix
+	if r.ExportGraph != "" {
+		graph = r.matrix.clone() // Before the links are adjusted in skipRepeats()
+	}
 	r.skipRepeats()
-	r.Cluster()
+	if r.AllelesFile != "" {
+		r.readAlleles()
+	}
+	if r.ConstraintsFile != "" {
+		r.readConstraints()
+	}
+	if r.AnchorsFile != "" && r.FromClusters == "" {
+		r.readReferenceAnchors()
+	}
+	if len(r.constraints) > 0 && r.Method != "" && r.Method != MethodAHC {
+		log.Warningf("Constraints are only used with --method %s", MethodAHC)
+	}
+	if r.FromClusters != "" {
+		r.readClusters(r.FromClusters)
+	} else if r.Ploidy > 1 {
+		r.PartitionHaplotypes()
+	} else {
+		r.runClustering()
+		if len(r.GroupSizes) > 0 || r.GenomeSize > 0 {
+			r.balanceClusters()
+		}
+		if r.AnchorsFile != "" {
+			r.labelAnchoredClusters()
+		}
+	}
 	// }
+	if len(r.history) > 0 {
+		r.writeMergeHistory()
+		r.writeDendrogram()
+	}
+	if r.ConstraintsFile != "" {
+		r.checkConstraints()
+	}
+	r.writeQualityReport()
+	r.writeContigClasses()
+	if r.ExportGraph != "" {
+		r.exportGraph(graph)
+	}
 	r.printClusters()
 	r.splitRE()
 	log.Notice("Success")
 }
 
+// runClustering clusters the contigs with the chosen method
+func (r *Partitioner) runClustering() {
+	if r.AutoK {
+		r.SelectK()
+	} else if r.Method == "" || r.Method == MethodAHC {
+		r.Cluster()
+	} else {
+		r.Community()
+	}
+}
+
 // makeTrivialClusters make a single cluster containing all contigs
 // except the really short ones
 func (r *Partitioner) makeTrivialClusters() {
@@ -67,6 +184,29 @@ func (r *Partitioner) makeTrivialClusters() {
 	r.clusters = clusters
 }
 
+// readAlleles imports the allele groups, same as in prune, and converts them
+// into pairs of contigs that should not be clustered together
+func (r *Partitioner) readAlleles() {
+	seen := map[[2]int]bool{}
+	for _, alleleGroup := range parseAllelesFile(r.AllelesFile) {
+		for i := 0; i < len(alleleGroup); i++ {
+			a, aok := r.contigToIdx[alleleGroup[i]]
+			for j := i + 1; j < len(alleleGroup); j++ {
+				b, bok := r.contigToIdx[alleleGroup[j]]
+				if !aok || !bok || a == b {
+					continue
+				}
+				pair := [2]int{min(a, b), max(a, b)}
+				if !seen[pair] {
+					seen[pair] = true
+					r.addConstraint(Constraint{a: a, b: b, weight: r.AllelicPenalty, allelic: true})
+				}
+			}
+		}
+	}
+	log.Noticef("Loaded %d allelic contig pairs as cannot-link constraints", len(seen))
+}
+
 // getRE extracts the restriction enzyme from the file name
 func (r *Partitioner) getRE() string {
 	s := path.Base(r.Contigsfile)
@@ -85,12 +225,13 @@ func (r *Partitioner) skipContigsWithFewREs() {
 
 	for i, contig := range r.contigs {
 		if contig.recounts < MinREs {
-			fmt.Printf("Contig #%d (%s) has %d RE sites -> MARKED SHORT\n",
+			log.Debugf("Contig #%d (%s) has %d RE sites -> MARKED SHORT",
 				i, contig.name, contig.recounts)
 			nShort++
 			shortRE += contig.recounts
 			shortLen += contig.length
 			contig.skip = true
+			contig.skipReason = "SHORT"
 		}
 	}
 	avgRE, avgLen := 0.0, 0
@@ -110,8 +251,10 @@ func (r *Partitioner) skipRepeats() {
 	N := len(r.contigs)
 	nLinks := make([]int64, N)
 	for i := 0; i < N; i++ {
-		for j := i + 1; j < N; j++ {
-			counts := r.matrix[i][j]
+		for j, counts := range r.matrix[i] {
+			if j <= i {
+				continue
+			}
 			totalLinks += counts
 			nLinks[i] += counts
 			nLinks[j] += counts
@@ -124,19 +267,21 @@ func (r *Partitioner) skipRepeats() {
 	repetitiveLength := 0
 	for i, contig := range r.contigs {
 		factor := float64(nLinks[i]) / nLinksAvg
+		contig.linkFactor = factor
 		// Adjust all link densitities by their repetitive factors
-		for j := 0; j < N; j++ {
-			if r.matrix[i][j] != 0 {
-				r.matrix[i][j] = int64(math.Ceil(float64(r.matrix[i][j]) / factor))
-			}
+		for j, counts := range r.matrix[i] {
+			r.matrix[i][j] = int64(math.Ceil(float64(counts) / factor))
 		}
 
 		if factor >= float64(r.MaxLinkDensity) {
-			fmt.Printf("Contig #%d (%s) has %.1fx the average number of Hi-C links -> MARKED REPETITIVE\n",
+			log.Debugf("Contig #%d (%s) has %.1fx the average number of Hi-C links -> MARKED REPETITIVE",
 				i, contig.name, factor)
 			nRepetitive++
 			repetitiveLength += contig.length
 			contig.skip = true
+			if contig.skipReason == "" {
+				contig.skipReason = "REPETITIVE"
+			}
 		}
 	}
 
@@ -150,11 +295,11 @@ func (r *Partitioner) skipRepeats() {
 		nRepetitive, avgRepetiveLength, r.MaxLinkDensity)
 }
 
-// makeMatrix creates an adjacency matrix containing normalized score
+// makeMatrix creates a sparse adjacency matrix containing normalized score
 func (r *Partitioner) makeMatrix() {
 	edges := parseDist(r.PairsFile)
 	N := len(r.contigs)
-	M := Make2DSliceInt64(N, N)
+	M := NewLinkMatrix(N)
 	longestSquared := int64(r.longestRE) * int64(r.longestRE)
 
 	// Load up all the contig pairs
@@ -167,6 +312,9 @@ func (r *Partitioner) makeMatrix() {
 
 		// Just normalize the counts
 		w := int64(e.nObservedLinks) * longestSquared / (int64(e.RE1) * int64(e.RE2))
+		if w == 0 {
+			continue
+		}
 		M[a][b] = w
 		M[b][a] = w
 	}
@@ -208,12 +356,55 @@ func (r *Partitioner) splitRE() {
 		for _, idx := range cl {
 			contigs = append(contigs, r.contigs[idx])
 		}
-		outfile := fmt.Sprintf("%s.%dg%d.txt", RemoveExt(r.Contigsfile), r.K, j+1)
+		outfile := fmt.Sprintf("%s.%s.txt", RemoveExt(r.Contigsfile), r.clusterLabel(j))
 		writeRE(outfile, contigs)
 		r.OutREfiles = append(r.OutREfiles, outfile)
 	}
 }
 
+// readClustersFile reads the groups in clusters.txt, as written by partition
+// #Group    nContigs    Contigs
+// 12g1      14          tig00001 tig00002 tig00003
+func readClustersFile(clustersfile string) ([]string, [][]string) {
+	recs := ReadCSVLines(clustersfile)
+	labels := make([]string, len(recs))
+	groups := make([][]string, len(recs))
+	for i, rec := range recs {
+		if len(rec) < 3 {
+			log.Fatalf("Malformed line: %s, expecting 3 columns", strings.Join(rec, "\t"))
+		}
+		labels[i] = rec[0]
+		groups[i] = strings.Fields(rec[2])
+	}
+	return labels, groups
+}
+
+// readClusters loads the clusters and their labels from clusters.txt. The
+// contigs in the clusters must all be in the RE counts file, and each contig
+// can only appear once.
+func (r *Partitioner) readClusters(clustersfile string) {
+	labels, groups := readClustersFile(clustersfile)
+	r.clusters = Clusters{}
+	r.labels = labels
+	seen := map[string]string{}
+	for j, names := range groups {
+		ids := make([]int, 0, len(names))
+		for _, name := range names {
+			idx, ok := r.contigToIdx[name]
+			if !ok {
+				log.Fatalf("Contig %s in %s not found in `%s`", name, labels[j], r.Contigsfile)
+			}
+			if label, ok := seen[name]; ok {
+				log.Fatalf("Contig %s found in both %s and %s", name, label, labels[j])
+			}
+			seen[name] = labels[j]
+			ids = append(ids, idx)
+		}
+		r.clusters[j] = ids
+	}
+	log.Noticef("Loaded %d contigs in %d clusters from `%s`", len(seen), len(groups), clustersfile)
+}
+
 // parseDist imports the edges of the contig into a slice of ContigPair
 // ContigPair stores the data structure of the distfile
 // #X      Y       Contig1 Contig2 RE1     RE2     ObservedLinks   ExpectedLinksIfAdjacent
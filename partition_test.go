@@ -0,0 +1,217 @@
+/*
+ *  partition_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tanghaibao/allhic"
+)
+
+// setupPartitioner copies the simulated groups of 15 contigs into a temp dir.
+// The links are the same within the groups, and the links between the groups
+// are often tied, so the clusters depend on how the ties are broken.
+func setupPartitioner(t *testing.T, k int) allhic.Partitioner {
+	dir := t.TempDir()
+	for _, file := range []string{"test.counts_GATC.txt", "test.pairs.txt"} {
+		data, err := ioutil.ReadFile(filepath.Join("tests", "simulation", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return allhic.Partitioner{
+		Contigsfile: filepath.Join(dir, "test.counts_GATC.txt"),
+		PairsFile:   filepath.Join(dir, "test.pairs.txt"),
+		K:           k, Ploidy: 1, Method: allhic.MethodAHC,
+		MinREs: allhic.MinREs, MaxLinkDensity: allhic.MaxLinkDensity,
+		NonInformativeRatio: allhic.NonInformativeRatio,
+	}
+}
+
+// writePartitionFiles writes the counts file of the contigs, each with 100 RE
+// sites, and the pairs file with the links between the contigs
+func writePartitionFiles(t *testing.T, countsFile, pairsFile string, contigs []string, links func(a, b string) int) {
+	counts := "#Contig\tRECounts\tLength\n"
+	for i, ctg := range contigs {
+		counts += fmt.Sprintf("%s\t100\t%d\n", ctg, 30000+i*1000)
+	}
+	if err := ioutil.WriteFile(countsFile, []byte(counts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pairs := allhic.PairsFileHeader
+	for i, a := range contigs {
+		for j := i + 1; j < len(contigs); j++ {
+			if n := links(a, contigs[j]); n > 0 {
+				pairs += fmt.Sprintf("%d\t%d\t%s\t%s\t100\t100\t%d\t%d.0\tok\n", i, j, a, contigs[j], n, n)
+			}
+		}
+	}
+	if err := ioutil.WriteFile(pairsFile, []byte(pairs), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readClusters reads the clusters.txt into the label and the contigs of each group
+func readClusters(t *testing.T, clustersFile string) map[string]string {
+	clusters := map[string]string{}
+	for _, row := range strings.Split(strings.TrimSpace(readFile(t, clustersFile)), "\n")[1:] {
+		words := strings.Split(row, "\t")
+		clusters[words[0]] = words[2]
+	}
+	return clusters
+}
+
+// readFile reads the file content as a string
+func readFile(t *testing.T, filename string) string {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestHierarchicalClustering(t *testing.T) {
+	// The expected clusters are from the clustering before the merge queue was
+	// introduced, and the merges with tied scores must be made in the same order
+	for _, k := range []int{2, 3, 4} {
+		p := setupPartitioner(t, k)
+		p.Run()
+		got := readFile(t, filepath.Join(filepath.Dir(p.PairsFile), "test.clusters.txt"))
+		expected := readFile(t, filepath.Join("tests", "simulation", fmt.Sprintf("test.k%d.clusters.txt", k)))
+		if got != expected {
+			t.Errorf("k = %d: expected clusters\n%s\ngot\n%s", k, expected, got)
+		}
+	}
+}
+
+func TestSelectK(t *testing.T) {
+	p := setupPartitioner(t, 0)
+	p.AutoK, p.MinK, p.MaxK = true, 1, 20
+	p.Run()
+	if p.K != 3 {
+		t.Errorf("Expected k = 3 to be selected, got %d", p.K)
+	}
+	// The hierarchy of 15 contigs cannot be cut into more than 4 clusters, the
+	// larger k replay all merges into a single cluster and are not selected
+	rows := strings.Split(strings.TrimRight(readFile(t,
+		filepath.Join(filepath.Dir(p.PairsFile), "test.clusters.auto.txt")), "\n"), "\n")[1:]
+	if len(rows) != 20 {
+		t.Fatalf("Expected 20 choices of k, got %d", len(rows))
+	}
+	for i, row := range rows {
+		words := strings.Split(row, "\t")
+		k, nClusters := i+1, i+1
+		if k > 4 {
+			nClusters = 1
+		}
+		if words[0] != fmt.Sprint(k) || words[1] != fmt.Sprint(nClusters) {
+			t.Errorf("Expected k = %d cut into %d clusters, got %s", k, nClusters, row)
+		}
+		if selected := words[6] == "*"; selected != (k == 3) {
+			t.Errorf("Expected only k = 3 to be selected, got %s", row)
+		}
+		// A single cluster has no inter-cluster links
+		if k == 1 && words[3] != "+Inf" {
+			t.Errorf("Expected infinite intra/inter ratio for k = 1, got %s", row)
+		}
+	}
+}
+
+func TestPartitionHaplotypes(t *testing.T) {
+	// 2 chromosomes x 2 haplotypes x 3 contigs, named as c<chr>h<hap>_<i>
+	contigs := []string{}
+	for c := 1; c <= 2; c++ {
+		for h := 1; h <= 2; h++ {
+			for i := 1; i <= 3; i++ {
+				contigs = append(contigs, fmt.Sprintf("c%dh%d_%d", c, h, i))
+			}
+		}
+	}
+	dir := t.TempDir()
+	countsFile := filepath.Join(dir, "test.counts_GATC.txt")
+	homologPairsFile := filepath.Join(dir, "test.pairs.txt")
+	pairsFile := filepath.Join(dir, "test.pairs.prune.txt")
+	// The homologs are linked before pruning, and only the same haplotype
+	// is linked after pruning
+	writePartitionFiles(t, countsFile, homologPairsFile, contigs, func(a, b string) int {
+		switch {
+		case a[:4] == b[:4]:
+			return 50
+		case a[:2] == b[:2]:
+			return 30
+		}
+		return 0
+	})
+	writePartitionFiles(t, countsFile, pairsFile, contigs, func(a, b string) int {
+		if a[:4] == b[:4] {
+			return 50
+		}
+		return 0
+	})
+
+	p := allhic.Partitioner{Contigsfile: countsFile, PairsFile: pairsFile, K: 2,
+		Ploidy: 2, HomologPairsFile: homologPairsFile, Method: allhic.MethodAHC,
+		MinREs: allhic.MinREs, MaxLinkDensity: allhic.MaxLinkDensity,
+		NonInformativeRatio: allhic.NonInformativeRatio}
+	p.Run()
+	clusters := readClusters(t, filepath.Join(dir, "test.pairs.clusters.txt"))
+	if len(clusters) != 4 {
+		t.Fatalf("Expected 4 haplotypes, got %v", clusters)
+	}
+	chroms := map[string]string{}
+	for label, names := range clusters {
+		words := strings.Fields(names)
+		for _, name := range words {
+			if name[:4] != words[0][:4] {
+				t.Errorf("Expected a single haplotype in %s, got %s", label, names)
+			}
+		}
+		chr := strings.Split(label, ".")[0]
+		if c, ok := chroms[chr]; ok && c != words[0][:2] {
+			t.Errorf("Expected the haplotypes of the same chromosome in %s, got %s and %s", chr, c, words[0][:2])
+		}
+		chroms[chr] = words[0][:2]
+	}
+	if len(chroms) != 2 {
+		t.Errorf("Expected 2 homologous groups, got %v", clusters)
+	}
+}
+
+func TestFromClusters(t *testing.T) {
+	p := setupPartitioner(t, 3)
+	p.Run()
+	clustersFile := filepath.Join(filepath.Dir(p.PairsFile), "test.clusters.txt")
+
+	// Move tig12 to the front of the last group by hand
+	edited := strings.Replace(readFile(t, clustersFile), " tig12", "", 1)
+	edited = strings.Replace(edited, "3g1\t5\t", "3g1\t4\t", 1)
+	edited = strings.Replace(edited, "3g3\t3\t", "3g3\t4\ttig12 ", 1)
+	if err := ioutil.WriteFile(clustersFile, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := allhic.Partitioner{Contigsfile: p.Contigsfile, PairsFile: p.PairsFile,
+		FromClusters: clustersFile, Ploidy: 1, MinREs: allhic.MinREs,
+		MaxLinkDensity: allhic.MaxLinkDensity, NonInformativeRatio: allhic.NonInformativeRatio}
+	q.Run()
+	if got := readFile(t, clustersFile); got != edited {
+		t.Errorf("Expected the edited clusters to be kept as is\n%s\ngot\n%s", edited, got)
+	}
+	data := readFile(t, allhic.RemoveExt(p.Contigsfile)+".3g3.txt")
+	if !strings.Contains(data, "tig12\t") {
+		t.Errorf("Expected tig12 in the counts file of 3g3, got\n%s", data)
+	}
+}
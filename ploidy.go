@@ -0,0 +1,130 @@
+/*
+ *  ploidy.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"fmt"
+)
+
+// clusterLabel returns the name of the j-th cluster, which is kgj by default,
+// or hierarchical names such as Chr3.hap2 in the polyploid mode
+func (r *Partitioner) clusterLabel(j int) string {
+	if j < len(r.labels) {
+		return r.labels[j]
+	}
+	return fmt.Sprintf("%dg%d", r.K, j+1)
+}
+
+// subPartitioner makes a Partitioner that only contains the given contigs,
+// with the normalized links and the constraints among them. The contigs keep
+// the skip flags from the parent Partitioner.
+func (r *Partitioner) subPartitioner(ids []int, k int) *Partitioner {
+	sub := &Partitioner{
+		Contigsfile:         r.Contigsfile,
+		PairsFile:           r.PairsFile,
+		K:                   k,
+		contigToIdx:         map[string]int{},
+		longestRE:           r.longestRE,
+		NonInformativeRatio: r.NonInformativeRatio,
+		Method:              r.Method,
+		Resolution:          r.Resolution,
+	}
+	idx := map[int]int{}
+	for i, id := range ids {
+		idx[id] = i
+		sub.contigs = append(sub.contigs, r.contigs[id])
+		sub.contigToIdx[r.contigs[id].name] = i
+	}
+	sub.matrix = NewLinkMatrix(len(ids))
+	for i, id := range ids {
+		for j, w := range r.matrix[id] {
+			if sj, ok := idx[j]; ok {
+				sub.matrix[i][sj] = w
+			}
+		}
+	}
+	for _, c := range r.constraints {
+		a, aok := idx[c.a]
+		b, bok := idx[c.b]
+		if aok && bok {
+			c.a, c.b = a, b
+			sub.addConstraint(c)
+		}
+	}
+	return sub
+}
+
+// subClusters maps the clusters of a sub-partitioner back to the contig IDs
+func subClusters(sub *Partitioner, ids []int) [][]int {
+	clusters := make([][]int, len(sub.clusters))
+	for j := 0; j < len(sub.clusters); j++ {
+		for _, i := range sub.clusters[j] {
+			clusters[j] = append(clusters[j], ids[i])
+		}
+	}
+	return clusters
+}
+
+// PartitionHaplotypes performs the two-level partitioning for polyploids. The
+// contigs are first clustered into homologous chromosome groups using the
+// unpruned pairs (HomologPairsFile), where the allelic and inter-allelic links
+// hold the homologs together. Then each group is split into Ploidy haplotypes
+// using the pruned pairs. The clusters are labeled as Chr3.hap2.
+func (r *Partitioner) PartitionHaplotypes() {
+	if r.HomologPairsFile == "" {
+		log.Fatalf("Unpruned pairs (--homologPairs) are needed to find the homologous groups")
+	}
+	homolog := &Partitioner{
+		Contigsfile:         r.Contigsfile,
+		PairsFile:           r.HomologPairsFile,
+		K:                   r.K,
+		MinREs:              r.MinREs,
+		MaxLinkDensity:      r.MaxLinkDensity,
+		NonInformativeRatio: r.NonInformativeRatio,
+		Method:              r.Method,
+		Resolution:          r.Resolution,
+		AutoK:               r.AutoK,
+		MinK:                r.MinK,
+		MaxK:                r.MaxK,
+	}
+	log.Noticef("Partition into homologous groups using `%s`", r.HomologPairsFile)
+	homolog.readRE()
+	homolog.skipContigsWithFewREs()
+	homolog.makeMatrix()
+	homolog.skipRepeats()
+	homolog.runClustering()
+	r.K = homolog.K
+
+	log.Noticef("Partition %d homologous groups into %d haplotypes using `%s`",
+		len(homolog.clusters), r.Ploidy, r.PairsFile)
+	clusters := Clusters{}
+	r.labels = []string{}
+	r.recovered = map[int]bool{}
+	for g := 0; g < len(homolog.clusters); g++ {
+		ids := homolog.clusters[g]
+		sub := r.subPartitioner(ids, r.Ploidy)
+		sub.runClustering()
+		haplotypes := subClusters(sub, ids)
+		for i := range sub.recovered {
+			r.recovered[ids[i]] = true
+		}
+		if len(haplotypes) == 0 {
+			haplotypes = [][]int{ids}
+		}
+		if len(haplotypes) != r.Ploidy {
+			log.Warningf("Homologous group %d split into %d haplotypes (ploidy = %d)",
+				g+1, len(haplotypes), r.Ploidy)
+		}
+		for h, haplotype := range haplotypes {
+			clusters[len(r.labels)] = haplotype
+			r.labels = append(r.labels, fmt.Sprintf("Chr%d.hap%d", g+1, h+1))
+		}
+	}
+	r.clusters = clusters
+}
@@ -11,9 +11,12 @@ package allhic
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"sort"
 	"strings"
 
 	hungarianAlgorithm "github.com/oddg/hungarian-algorithm"
@@ -23,8 +26,60 @@ import (
 type Pruner struct {
 	AllelesFile  string
 	PairsFile    string
+	ClmFile      string  // Optional, write a pruned copy of the clmfile
+	REFile       string  // Optional, needed to normalize by contig length
+	Normalize    string  // One of none, re, expected, length
+	MinRatio     float64 // Weak if observed / expected links is below this
+	MinLinks     int     // Weak if observed links is below this
+	TopN         int     // Weak if not in the top N partners of either contig
+	Report       bool    // Write the per allele group report in JSON
 	edges        []ContigPair
 	alleleGroups []AlleleGroup
+	matchings    map[[2]int]*MatchingReport // (groupA, groupB) => bipartite matching
+	matchingKeys [][2]int                   // Keys of matchings in the order of evaluation
+}
+
+// PruneReport summarizes the pruning decisions
+type PruneReport struct {
+	Normalize       string              `json:"normalize"`
+	Groups          []AlleleGroupReport `json:"groups"`
+	Matchings       []MatchingReport    `json:"matchings"`
+	AmbiguousGroups [][2]int            `json:"ambiguous_groups"`
+	IsolatedContigs []string            `json:"isolated_contigs"`
+}
+
+// AlleleGroupReport lists the allelic edges pruned within an allele group
+type AlleleGroupReport struct {
+	ID      int          `json:"id"`
+	Contigs []string     `json:"contigs"`
+	Edges   []EdgeReport `json:"edges"`
+}
+
+// MatchingReport records the bipartite matching between two allele groups.
+// Solution[i] is the index of the contig in ContigsB matched to the i-th contig
+// in ContigsA, or -1 if the contig is unmatched. RunnerUp is the best total if
+// any of the matched pairs in the solution is disallowed.
+type MatchingReport struct {
+	GroupA    int          `json:"group_a"`
+	GroupB    int          `json:"group_b"`
+	ContigsA  []string     `json:"contigs_a"`
+	ContigsB  []string     `json:"contigs_b"`
+	Scores    [][]float64  `json:"scores"`
+	Solution  []int        `json:"solution"`
+	Total     float64      `json:"total"`
+	RunnerUp  float64      `json:"runner_up"`
+	Ambiguous bool         `json:"ambiguous"`
+	Edges     []EdgeReport `json:"edges"`
+}
+
+// EdgeReport stores the scores and the final label of a contig pair
+type EdgeReport struct {
+	A       string  `json:"a"`
+	B       string  `json:"b"`
+	Links   int     `json:"links"`
+	Score   float64 `json:"score"`
+	Matched bool    `json:"matched"`
+	Label   string  `json:"label"`
 }
 
 // ContigAB is used to get a pair of contigs
@@ -42,19 +97,172 @@ type CtgAlleleGroupPair struct {
 // Run calls the pruning steps
 // The pruning algorithm is a heuristic method that removes the following pairs:
 //
-// 1. Allelic, these are directly the pairs of allelic contigs given in the allele table
-// 2. Cross-allelic, these are any contigs that connect to the allelic contigs so we only
-//    keep the best contig pair
+//  1. Allelic, these are directly the pairs of allelic contigs given in the allele table
+//  2. Cross-allelic, these are any contigs that connect to the allelic contigs so we only
+//     keep the best contig pair
+//  3. Weak, these are the remaining pairs with too few links, or too few links compared
+//     to expectation, or not among the strongest partners of either contig
 //
-// Pruned edges are then annotated as allelic/cross-allelic/ok
+// Pruned edges are then annotated as allelic/cross-allelic/weak/ok
 func (r *Pruner) Run() {
 	r.edges = parseDist(r.PairsFile)
 	r.alleleGroups = parseAllelesFile(r.AllelesFile)
+	r.prepareNormalization()
 	r.pruneAllelic()
 	r.pruneCrossAllelicBipartiteMatching()
 	// r.pruneCrossAllelic()
+	r.pruneWeak()
 	newPairsFile := RemoveExt(r.PairsFile) + ".prune.txt"
 	writePairsFile(newPairsFile, r.edges)
+	if r.Report {
+		r.writeReport(RemoveExt(r.PairsFile) + ".prune.report.json")
+	}
+	if r.ClmFile != "" {
+		r.writePrunedClm(RemoveExt(r.ClmFile) + ".prune.clm")
+	}
+}
+
+// writePrunedClm writes a copy of the clmfile without the pruned contig pairs,
+// so that `optimize` uses the same evidence as `partition`
+func (r *Pruner) writePrunedClm(clmfile string) {
+	prunedPairs := map[ContigAB]bool{}
+	for _, edge := range r.edges {
+		if edge.label == "ok" {
+			continue
+		}
+		prunedPairs[ContigAB{edge.at, edge.bt}] = true
+		prunedPairs[ContigAB{edge.bt, edge.at}] = true
+	}
+
+	fh := mustOpen(r.ClmFile)
+	defer fh.Close()
+	log.Noticef("Parse clmfile `%s`", r.ClmFile)
+	reader := bufio.NewReader(fh)
+	f, err := os.Create(clmfile)
+	ErrorAbort(err)
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	kept, pruned := 0, 0
+	for {
+		row, err := reader.ReadString('\n')
+		row = strings.TrimSpace(row)
+		if row == "" && err == io.EOF {
+			break
+		}
+		if row == "" {
+			continue
+		}
+		// tig00030676+ tig00077819-       7       126178 152952 ...
+		abtig := strings.Fields(strings.SplitN(row, "\t", 2)[0])
+		if len(abtig) != 2 {
+			log.Fatalf("Malformed line: %s", row)
+		}
+		atig, btig := abtig[0], abtig[1]
+		at, bt := atig[:len(atig)-1], btig[:len(btig)-1]
+		if prunedPairs[ContigAB{at, bt}] {
+			pruned++
+			continue
+		}
+		fmt.Fprintln(w, row)
+		kept++
+	}
+	w.Flush()
+	log.Noticef("Pruned clm lines: %s, written to `%s`", Percentage(pruned, kept+pruned), clmfile)
+}
+
+// prepareNormalization checks the normalization method, and loads the contig
+// lengths if we normalize by length
+func (r *Pruner) prepareNormalization() {
+	if r.Normalize == "" {
+		r.Normalize = NormalizeNone
+	}
+	switch r.Normalize {
+	case NormalizeNone, NormalizeRE, NormalizeExpected:
+	case NormalizeLength:
+		if r.REFile == "" {
+			log.Fatalf("Normalize by %s requires the RE counts file", NormalizeLength)
+		}
+		reCounts := RECountsFile{Filename: r.REFile}
+		reCounts.ParseRecords()
+		lengths := map[string]int{}
+		for _, rec := range reCounts.Records {
+			lengths[rec.Contig] = rec.Length
+		}
+		for i := range r.edges {
+			r.edges[i].L1 = lengths[r.edges[i].at]
+			r.edges[i].L2 = lengths[r.edges[i].bt]
+		}
+	default:
+		log.Fatalf("Unknown normalization `%s`, must be one of %s, %s, %s, %s", r.Normalize,
+			NormalizeNone, NormalizeRE, NormalizeExpected, NormalizeLength)
+	}
+	if r.Normalize == NormalizeNone {
+		log.Noticef("Link scores normalized by: %s", r.Normalize)
+		return
+	}
+	// Without the values to normalize with, the raw links are used throughout
+	normalized := 0
+	for i := range r.edges {
+		if r.canNormalize(&r.edges[i]) {
+			normalized++
+		}
+	}
+	if normalized == 0 && len(r.edges) > 0 {
+		log.Warningf("No pairs can be normalized by %s, using raw links instead", r.Normalize)
+		r.Normalize = NormalizeNone
+		return
+	}
+	if normalized < len(r.edges) {
+		log.Warningf("%d pairs cannot be normalized by %s and are scored 0",
+			len(r.edges)-normalized, r.Normalize)
+	}
+	log.Noticef("Link scores normalized by: %s (%s)", r.Normalize, Percentage(normalized, len(r.edges)))
+}
+
+// canNormalize checks if the edge has the values needed by the normalization
+func (r *Pruner) canNormalize(edge *ContigPair) bool {
+	switch r.Normalize {
+	case NormalizeRE:
+		return edge.RE1 > 0 && edge.RE2 > 0
+	case NormalizeExpected:
+		return edge.nExpectedLinks > 0
+	case NormalizeLength:
+		return edge.L1 > 0 && edge.L2 > 0
+	}
+	return false
+}
+
+// score returns the strength of an edge, normalized so that long contigs
+// (with more RE sites) do not always outcompete short contigs. The edges that
+// cannot be normalized are scored 0, rather than the raw links, which are not
+// comparable to the normalized scores.
+func (r *Pruner) score(edge *ContigPair) float64 {
+	links := float64(edge.nObservedLinks)
+	if r.Normalize == NormalizeNone {
+		return links
+	}
+	if !r.canNormalize(edge) {
+		return 0
+	}
+	switch r.Normalize {
+	case NormalizeRE:
+		return links / float64(edge.RE1) / float64(edge.RE2)
+	case NormalizeExpected:
+		return links / edge.nExpectedLinks
+	case NormalizeLength:
+		return links / float64(edge.L1) / float64(edge.L2)
+	}
+	return links
+}
+
+// crossAllelicLabel returns the label of pruned cross-allelic edges, with the
+// normalization recorded when it is used
+func (r *Pruner) crossAllelicLabel(a, b string) string {
+	if r.Normalize == NormalizeNone {
+		return fmt.Sprintf("cross-allelic(%s|%s)", a, b)
+	}
+	return fmt.Sprintf("cross-allelic[%s](%s|%s)", r.Normalize, a, b)
 }
 
 // pruneAllelic removes the allelic contigs given in the allele table
@@ -104,13 +312,14 @@ func (r *Pruner) pruneCrossAllelicBipartiteMatching() {
 	ctgToAlleleGroup := r.getCtgToAlleleGroup()
 
 	// Store scores for contig pairs
-	ctgPairScores := map[ContigAB]int{}
-	for _, edge := range r.edges { // First pass collects all scores
+	ctgPairScores := map[ContigAB]float64{}
+	for i, edge := range r.edges { // First pass collects all scores
 		if edge.label != "ok" { // We skip the allelic pairs since these are already removed
 			continue
 		}
-		ctgPairScores[ContigAB{edge.at, edge.bt}] = edge.nObservedLinks
-		ctgPairScores[ContigAB{edge.bt, edge.at}] = edge.nObservedLinks
+		score := r.score(&r.edges[i])
+		ctgPairScores[ContigAB{edge.at, edge.bt}] = score
+		ctgPairScores[ContigAB{edge.bt, edge.at}] = score
 	}
 
 	// Now iterate over all edges and mark
@@ -136,7 +345,7 @@ func (r *Pruner) pruneCrossAllelicBipartiteMatching() {
 // used in bipartite matching between two allele groups on either side of this
 // edge, since a-b can both be within a number of AlleleGroups. We need to check
 // each pair one by one.
-func (r *Pruner) isStrongEdgeInBipartiteMatchingGroups(edge *ContigPair, ctgToAlleleGroup map[string][]int, ctgPairScores map[ContigAB]int) bool {
+func (r *Pruner) isStrongEdgeInBipartiteMatchingGroups(edge *ContigPair, ctgToAlleleGroup map[string][]int, ctgPairScores map[ContigAB]float64) bool {
 	ag, aok := ctgToAlleleGroup[edge.at]
 	bg, bok := ctgToAlleleGroup[edge.bt]
 	if !aok || !bok {
@@ -144,10 +353,10 @@ func (r *Pruner) isStrongEdgeInBipartiteMatchingGroups(edge *ContigPair, ctgToAl
 	}
 	for _, ai := range ag {
 		for _, bi := range bg {
-			aGroup := r.alleleGroups[ai]
-			bGroup := r.alleleGroups[bi]
-			if !r.isStrongEdgeInBipartiteMatching(edge, aGroup, bGroup, ctgPairScores) {
-				edge.label = fmt.Sprintf("cross-allelic(%s|%s)", strings.Join(aGroup, ","), strings.Join(bGroup, ","))
+			if !r.isStrongEdgeInBipartiteMatching(edge, ai, bi, ctgPairScores) {
+				aGroup := r.alleleGroups[ai]
+				bGroup := r.alleleGroups[bi]
+				edge.label = r.crossAllelicLabel(strings.Join(aGroup, ","), strings.Join(bGroup, ","))
 				return false
 			}
 		}
@@ -160,22 +369,40 @@ func (r *Pruner) isStrongEdgeInBipartiteMatchingGroups(edge *ContigPair, ctgToAl
 // edge. Note that this function is called by
 // isStrongEdgeInBipartiteMatchingGroups(), and only operates on a single pair
 // of AlleleGroups.
-func (r *Pruner) isStrongEdgeInBipartiteMatching(edge *ContigPair, aGroup AlleleGroup, bGroup AlleleGroup, ctgPairScores map[ContigAB]int) bool {
+func (r *Pruner) isStrongEdgeInBipartiteMatching(edge *ContigPair, ai, bi int, ctgPairScores map[ContigAB]float64) bool {
+	matching := r.getMatching(ai, bi, ctgPairScores)
+	ti, tj := -1, -1
+	for i, at := range matching.ContigsA {
+		if at == edge.at {
+			ti = i
+		}
+	}
+	for j, bt := range matching.ContigsB {
+		if bt == edge.bt {
+			tj = j
+		}
+	}
+	return matching.Solution[ti] == tj
+}
+
+// getMatching solves the bipartite matching between two allele groups. The
+// solution only depends on the pair of groups, so it is cached for all the
+// edges between the two groups.
+func (r *Pruner) getMatching(ai, bi int, ctgPairScores map[ContigAB]float64) *MatchingReport {
+	key := [2]int{ai, bi}
+	if matching, ok := r.matchings[key]; ok {
+		return matching
+	}
+	aGroup := r.alleleGroups[ai]
+	bGroup := r.alleleGroups[bi]
 	// Build a square matrix that contain matching scores
 	aN := len(aGroup)
 	bN := len(bGroup)
 	N := max(aN, bN)
-	S := Make2DSlice(N, N)
-	ti, tj := -1, -1
+	S := Make2DSliceFloat64(N, N)
 	// Populate the entries
 	for i, at := range aGroup {
-		if at == edge.at {
-			ti = i
-		}
 		for j, bt := range bGroup {
-			if bt == edge.bt {
-				tj = j
-			}
 			ctgPair := ContigAB{at, bt}
 			if score, ok := ctgPairScores[ctgPair]; ok {
 				S[i][j] = score
@@ -183,10 +410,81 @@ func (r *Pruner) isStrongEdgeInBipartiteMatching(edge *ContigPair, aGroup Allele
 		}
 	}
 	// Solve the matching problem using Hungarian algorithm
-	solution := maxBipartiteMatchingWithWeights(S)
-	ans := solution[ti] == tj
-	// fmt.Println(edge.at, edge.bt, aGroup, bGroup, S, solution, ans)
-	return ans
+	solution := maxBipartiteMatchingWithWeights(quantizeWeights(S))
+	// Contigs matched to the padded rows or columns are unmatched
+	trimmed := make([]int, aN)
+	for i := range trimmed {
+		trimmed[i] = -1
+		if solution[i] < bN {
+			trimmed[i] = solution[i]
+		}
+	}
+	matching := &MatchingReport{
+		GroupA:   ai,
+		GroupB:   bi,
+		ContigsA: aGroup,
+		ContigsB: bGroup,
+		Scores:   S,
+		Solution: trimmed,
+		Total:    matchingTotal(S, solution),
+	}
+	if r.matchings == nil {
+		r.matchings = map[[2]int]*MatchingReport{}
+	}
+	r.matchings[key] = matching
+	r.matchingKeys = append(r.matchingKeys, key)
+	return matching
+}
+
+// matchingTotal sums up the weights of the matched pairs
+func matchingTotal(weights [][]float64, solution []int) float64 {
+	total := 0.0
+	for i, j := range solution {
+		total += weights[i][j]
+	}
+	return total
+}
+
+// runnerUpTotal finds the best total of the matching when any one of the
+// matched pairs in the best solution is disallowed
+func runnerUpTotal(weights [][]float64, solution []int) float64 {
+	N := len(weights)
+	runnerUp := 0.0
+	for i, j := range solution {
+		if weights[i][j] <= 0 {
+			continue
+		}
+		W := Make2DSliceFloat64(N, N)
+		for k := range weights {
+			copy(W[k], weights[k])
+		}
+		W[i][j] = 0
+		alternative := maxBipartiteMatchingWithWeights(quantizeWeights(W))
+		runnerUp = math.Max(runnerUp, matchingTotal(W, alternative))
+	}
+	return runnerUp
+}
+
+// quantizeWeights converts the float weights to integers for the Hungarian
+// algorithm, the weights are scaled so that the largest weight is QuantizeScale
+func quantizeWeights(weights [][]float64) [][]int {
+	maxCell := 0.0
+	for _, row := range weights {
+		for _, cell := range row {
+			maxCell = math.Max(maxCell, cell)
+		}
+	}
+	N := len(weights)
+	Q := Make2DSlice(N, N)
+	if maxCell == 0 {
+		return Q
+	}
+	for i, row := range weights {
+		for j, cell := range row {
+			Q[i][j] = int(math.Round(cell / maxCell * QuantizeScale))
+		}
+	}
+	return Q
 }
 
 // maxBipartiteMatchingWithWeights calculates the bipartite matching using the
@@ -213,6 +511,179 @@ func maxBipartiteMatchingWithWeights(weights [][]int) []int {
 	return solution
 }
 
+// pruneWeak removes the edges that are weak in absolute counts, weak relative
+// to the expected number of links, or not in the top N partners of either contig.
+// The last criterion uses the normalized scores so short contigs can still
+// keep their best partners.
+func (r *Pruner) pruneWeak() {
+	if r.MinLinks <= 0 && r.MinRatio <= 0 && r.TopN <= 0 {
+		return
+	}
+
+	pruned, prunedLinks := 0, 0
+	total, totalLinks := 0, 0
+	markPruned := func(i int, label string) {
+		r.edges[i].label = label
+		pruned++
+		prunedLinks += r.edges[i].nObservedLinks
+	}
+	for i, edge := range r.edges {
+		if edge.label != "ok" {
+			continue
+		}
+		total++
+		totalLinks += edge.nObservedLinks
+		if edge.nObservedLinks < r.MinLinks {
+			markPruned(i, fmt.Sprintf("weak(links=%d<%d)", edge.nObservedLinks, r.MinLinks))
+			continue
+		}
+		if r.MinRatio > 0 && edge.nExpectedLinks > 0 {
+			ratio := float64(edge.nObservedLinks) / edge.nExpectedLinks
+			if ratio < r.MinRatio {
+				markPruned(i, fmt.Sprintf("weak(ratio=%.3g<%.3g)", ratio, r.MinRatio))
+			}
+		}
+	}
+
+	if r.TopN > 0 {
+		// Rank the partners of each contig by decreasing score
+		partners := map[string][]int{}
+		for i, edge := range r.edges {
+			if edge.label != "ok" {
+				continue
+			}
+			partners[edge.at] = append(partners[edge.at], i)
+			partners[edge.bt] = append(partners[edge.bt], i)
+		}
+		ranks := make([][2]int, len(r.edges))
+		for ctg, ids := range partners {
+			sort.SliceStable(ids, func(i, j int) bool {
+				return r.score(&r.edges[ids[i]]) > r.score(&r.edges[ids[j]])
+			})
+			for rank, id := range ids {
+				if r.edges[id].at == ctg {
+					ranks[id][0] = rank + 1
+				} else {
+					ranks[id][1] = rank + 1
+				}
+			}
+		}
+		for i, edge := range r.edges {
+			if edge.label != "ok" {
+				continue
+			}
+			if ranks[i][0] > r.TopN && ranks[i][1] > r.TopN {
+				markPruned(i, fmt.Sprintf("weak(rank=%d,%d>%d)", ranks[i][0], ranks[i][1], r.TopN))
+			}
+		}
+	}
+	log.Noticef("Weak pairs pruned (MinLinks = %d, MinRatio = %.3g, TopN = %d): %s, prunedLinks: %s",
+		r.MinLinks, r.MinRatio, r.TopN, Percentage(pruned, total), Percentage(prunedLinks, totalLinks))
+}
+
+// writeReport writes the pruning decisions for each allele group to a JSON
+// file, including the bipartite matchings, near-ties in the matchings, and the
+// contigs that are left without any edges after pruning
+func (r *Pruner) writeReport(reportfile string) {
+	report := PruneReport{Normalize: r.Normalize}
+	edgeIdx := map[ContigAB]int{}
+	hasEdges := map[string]bool{}
+	for i, edge := range r.edges {
+		edgeIdx[ContigAB{edge.at, edge.bt}] = i
+		edgeIdx[ContigAB{edge.bt, edge.at}] = i
+		hasEdges[edge.at] = hasEdges[edge.at] || edge.label == "ok"
+		hasEdges[edge.bt] = hasEdges[edge.bt] || edge.label == "ok"
+	}
+	edgeReport := func(a, b string, matched bool) (EdgeReport, bool) {
+		i, ok := edgeIdx[ContigAB{a, b}]
+		if !ok {
+			return EdgeReport{}, false
+		}
+		edge := &r.edges[i]
+		return EdgeReport{A: a, B: b, Links: edge.nObservedLinks, Score: r.score(edge),
+			Matched: matched, Label: edge.label}, true
+	}
+
+	for groupID, alleleGroup := range r.alleleGroups {
+		group := AlleleGroupReport{ID: groupID, Contigs: alleleGroup, Edges: []EdgeReport{}}
+		for i := 0; i < len(alleleGroup); i++ {
+			for j := i + 1; j < len(alleleGroup); j++ {
+				if e, ok := edgeReport(alleleGroup[i], alleleGroup[j], false); ok {
+					group.Edges = append(group.Edges, e)
+				}
+			}
+		}
+		report.Groups = append(report.Groups, group)
+	}
+
+	report.Matchings = []MatchingReport{}
+	report.AmbiguousGroups = [][2]int{}
+	for _, key := range r.matchingKeys {
+		matching := *r.matchings[key]
+		matching.RunnerUp = runnerUpTotal(matching.Scores, padSolution(matching.Solution, len(matching.Scores)))
+		matching.Ambiguous = matching.Total > 0 &&
+			(matching.Total-matching.RunnerUp)/matching.Total < TieRatio
+		if matching.Ambiguous {
+			report.AmbiguousGroups = append(report.AmbiguousGroups, key)
+		}
+		matching.Edges = []EdgeReport{}
+		for i, at := range matching.ContigsA {
+			for j, bt := range matching.ContigsB {
+				if e, ok := edgeReport(at, bt, matching.Solution[i] == j); ok {
+					matching.Edges = append(matching.Edges, e)
+				}
+			}
+		}
+		report.Matchings = append(report.Matchings, matching)
+	}
+
+	report.IsolatedContigs = []string{}
+	for ctg, ok := range hasEdges {
+		if !ok {
+			report.IsolatedContigs = append(report.IsolatedContigs, ctg)
+		}
+	}
+	sort.Strings(report.IsolatedContigs)
+
+	s, _ := json.MarshalIndent(report, "", "\t")
+	f, err := os.Create(reportfile)
+	ErrorAbort(err)
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	w.Write(s)
+	w.Flush()
+	log.Noticef("Pruning report (%d matchings, %d ambiguous, %d isolated contigs) written to `%s`",
+		len(report.Matchings), len(report.AmbiguousGroups), len(report.IsolatedContigs), reportfile)
+}
+
+// padSolution restores the full solution of the square matrix from the trimmed
+// solution, by assigning the unmatched rows to the unused columns
+func padSolution(trimmed []int, N int) []int {
+	solution := make([]int, N)
+	used := make([]bool, N)
+	for i, j := range trimmed {
+		solution[i] = j
+		if j >= 0 {
+			used[j] = true
+		}
+	}
+	for i := len(trimmed); i < N; i++ {
+		solution[i] = -1
+	}
+	next := 0
+	for i := range solution {
+		if solution[i] >= 0 {
+			continue
+		}
+		for used[next] {
+			next++
+		}
+		solution[i] = next
+		used[next] = true
+	}
+	return solution
+}
+
 // getCtgToAlleleGroup returns contig to List of alleleGroups
 func (r *Pruner) getCtgToAlleleGroup() map[string][]int {
 	// Store contig to list of alleleGroups since each contig can be in different alleleGroups
@@ -237,23 +708,26 @@ func (r *Pruner) pruneCrossAllelic() {
 	ctgToAlleleGroup := r.getCtgToAlleleGroup()
 
 	// Store the best match of each contig to an allele group
-	scores := map[CtgAlleleGroupPair]int{} // (ctg, alleleGroupID) => score
-	for _, edge := range r.edges {
+	scores := map[CtgAlleleGroupPair]float64{} // (ctg, alleleGroupID) => score
+	for i, edge := range r.edges {
 		if edge.label != "ok" { // We skip the allelic pairs since these are already removed
 			continue
 		}
-		updateScore(edge.at, edge.bt, edge.nObservedLinks, ctgToAlleleGroup, scores)
-		updateScore(edge.bt, edge.at, edge.nObservedLinks, ctgToAlleleGroup, scores)
+		score := r.score(&r.edges[i])
+		updateScore(edge.at, edge.bt, score, ctgToAlleleGroup, scores)
+		updateScore(edge.bt, edge.at, score, ctgToAlleleGroup, scores)
 	}
 
 	// Now iterate over all edges and mark
 	pruned, prunedLinks := 0, 0
 	total, totalLinks := 0, 0
 	for i, edge := range r.edges {
+		score := r.score(&r.edges[i])
 		aBestScore := getScore(edge.at, edge.bt, ctgToAlleleGroup, scores)
 		bBestScore := getScore(edge.bt, edge.at, ctgToAlleleGroup, scores)
-		if edge.nObservedLinks < aBestScore && edge.nObservedLinks < bBestScore {
-			r.edges[i].label = fmt.Sprintf("cross-allelic(%d|%d)", aBestScore, bBestScore)
+		if score < aBestScore && score < bBestScore {
+			r.edges[i].label = r.crossAllelicLabel(
+				fmt.Sprintf("%.4g", aBestScore), fmt.Sprintf("%.4g", bBestScore))
 			pruned++
 			prunedLinks += edge.nObservedLinks
 		}
@@ -264,14 +738,14 @@ func (r *Pruner) pruneCrossAllelic() {
 		Percentage(pruned, total), Percentage(prunedLinks, totalLinks))
 }
 
-// updateScore takes a potential pair of contigs and update scores
-func updateScore(at, bt string, score int, ctgToAlleleGroup map[string][]int, scores map[CtgAlleleGroupPair]int) {
+// updateScore takes a potential pair of contigs and update scores. The score
+// should be normalized (see Pruner.score()) since contig size can affect the
+// number of links, and as a result, the "best match" in raw counts may be wrong
+func updateScore(at, bt string, score float64, ctgToAlleleGroup map[string][]int, scores map[CtgAlleleGroupPair]float64) {
 	if gg, ok := ctgToAlleleGroup[bt]; ok {
 		// Update through all alleleGroups that contig b sits in
 		for _, bg := range gg {
 			pair := CtgAlleleGroupPair{at, bg}
-			// TODO: the score should ideally be 'normalized' score, since contig size can affect size, and as a
-			//       result, the "best match" in absolute score may be wrong
 			if sc, ok := scores[pair]; ok {
 				if sc < score {
 					scores[pair] = score
@@ -284,9 +758,9 @@ func updateScore(at, bt string, score int, ctgToAlleleGroup map[string][]int, sc
 }
 
 // getScore takes a pair of contigs and get the maximum score to the allele group
-func getScore(at, bt string, ctgToAlleleGroup map[string][]int, scores map[CtgAlleleGroupPair]int) int {
+func getScore(at, bt string, ctgToAlleleGroup map[string][]int, scores map[CtgAlleleGroupPair]float64) float64 {
 	if gg, ok := ctgToAlleleGroup[bt]; ok {
-		maxScore := -1
+		maxScore := -1.0
 		for _, bg := range gg {
 			maxScoreAlleleGroup, _ := scores[CtgAlleleGroupPair{at, bg}]
 			if maxScore < maxScoreAlleleGroup {
@@ -317,7 +791,8 @@ func parseAllelesFile(filename string) []AlleleGroup {
 // parseAssociationLog imports contig allelic relationship from purge-haplotigs
 // File has the followign format:
 // tig00030660,PRIMARY -> tig00003333,HAPLOTIG
-//                     -> tig00038686,HAPLOTIG
+//
+//	-> tig00038686,HAPLOTIG
 func parseAssociationLog(associationFile string) []AlleleGroup {
 	log.Noticef("Parse association log `%s`", associationFile)
 	fh := mustOpen(associationFile)
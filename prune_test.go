@@ -0,0 +1,282 @@
+/*
+ *  prune_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tanghaibao/allhic"
+)
+
+// pruneEdge is a contig pair in the pairs file for pruning
+type pruneEdge struct {
+	a, b     string
+	re1, re2 int
+	links    int
+	expected float64
+}
+
+// setupPruner writes the alleles table and the pairs file into a temp dir
+func setupPruner(t *testing.T, alleleGroups [][]string, edges []pruneEdge) allhic.Pruner {
+	dir := t.TempDir()
+	allelesFile := filepath.Join(dir, "alleles.table")
+	alleles := ""
+	for i, group := range alleleGroups {
+		alleles += fmt.Sprintf("Chr1\t%d\t%s\n", (i+1)*1000, strings.Join(group, "\t"))
+	}
+	if err := ioutil.WriteFile(allelesFile, []byte(alleles), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pairsFile := filepath.Join(dir, "test.pairs.txt")
+	pairs := allhic.PairsFileHeader
+	for i, e := range edges {
+		pairs += fmt.Sprintf("%d\t%d\t%s\t%s\t%d\t%d\t%d\t%.1f\tok\n",
+			i, i+1, e.a, e.b, e.re1, e.re2, e.links, e.expected)
+	}
+	if err := ioutil.WriteFile(pairsFile, []byte(pairs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return allhic.Pruner{AllelesFile: allelesFile, PairsFile: pairsFile}
+}
+
+// prunedLabels reads the labels of the contig pairs after pruning
+func prunedLabels(t *testing.T, p allhic.Pruner) map[string]string {
+	data, err := ioutil.ReadFile(allhic.RemoveExt(p.PairsFile) + ".prune.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{}
+	for _, row := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+		words := strings.Split(row, "\t")
+		labels[words[2]+"-"+words[3]] = words[8]
+	}
+	return labels
+}
+
+// crossAllelicEdges link two allele groups, where a1 and b1 have 10x the RE
+// sites of a2 and b2. The raw links favor a1-b1 + a2-b2, and the normalized
+// links favor a1-b2 + a2-b1.
+func crossAllelicEdges(re1, re2 int) []pruneEdge {
+	return []pruneEdge{
+		{"a1", "b1", re1, re1, 200, 100},
+		{"a2", "b2", re2, re2, 10, 100},
+		{"a1", "b2", re1, re2, 100, 100},
+		{"a2", "b1", re2, re1, 100, 100},
+	}
+}
+
+func TestPruneNormalize(t *testing.T) {
+	groups := [][]string{{"a1", "a2"}, {"b1", "b2"}}
+	tests := []struct {
+		normalize string
+		re1, re2  int
+		effective string
+		kept      []string
+	}{
+		// The raw links are used by default
+		{"", 1000, 100, allhic.NormalizeNone, []string{"a1-b1", "a2-b2"}},
+		{allhic.NormalizeRE, 1000, 100, allhic.NormalizeRE, []string{"a1-b2", "a2-b1"}},
+		// Without the RE sites the raw links are used
+		{allhic.NormalizeRE, 0, 0, allhic.NormalizeNone, []string{"a1-b1", "a2-b2"}},
+	}
+	for _, tt := range tests {
+		p := setupPruner(t, groups, crossAllelicEdges(tt.re1, tt.re2))
+		p.Normalize = tt.normalize
+		p.Report = true
+		p.Run()
+
+		labels := prunedLabels(t, p)
+		for _, pair := range tt.kept {
+			if labels[pair] != "ok" {
+				t.Errorf("Normalize %q: expected %s to be kept, got label %s", tt.normalize, pair, labels[pair])
+			}
+		}
+		nKept := 0
+		for _, label := range labels {
+			if label == "ok" {
+				nKept++
+			}
+		}
+		if nKept != len(tt.kept) {
+			t.Errorf("Normalize %q: expected %d pairs kept, got %v", tt.normalize, len(tt.kept), labels)
+		}
+
+		data, err := ioutil.ReadFile(allhic.RemoveExt(p.PairsFile) + ".prune.report.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report allhic.PruneReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Normalize != tt.effective {
+			t.Errorf("Normalize %q: expected %s in the report, got %s", tt.normalize, tt.effective, report.Normalize)
+		}
+	}
+}
+
+func TestPruneNormalizeMissing(t *testing.T) {
+	// a1-b1 has the most links but no RE sites, and cannot beat the pairs
+	// that are normalized
+	edges := []pruneEdge{
+		{"a1", "b1", 0, 0, 500, 100},
+		{"a2", "b2", 100, 100, 10, 100},
+		{"a1", "b2", 100, 100, 100, 100},
+		{"a2", "b1", 100, 100, 100, 100},
+	}
+	p := setupPruner(t, [][]string{{"a1", "a2"}, {"b1", "b2"}}, edges)
+	p.Normalize = allhic.NormalizeRE
+	p.Run()
+	labels := prunedLabels(t, p)
+	for pair, kept := range map[string]bool{"a1-b1": false, "a2-b2": false, "a1-b2": true, "a2-b1": true} {
+		if (labels[pair] == "ok") != kept {
+			t.Errorf("Expected %s to be kept: %v, got label %s", pair, kept, labels[pair])
+		}
+	}
+}
+
+func TestPruneWeak(t *testing.T) {
+	groups := [][]string{{"x1", "x2"}}
+	edges := []pruneEdge{
+		{"c1", "c2", 100, 100, 5, 10},
+		{"c1", "c3", 100, 100, 50, 100},
+		{"c2", "c3", 100, 100, 50, 10},
+		{"c3", "c4", 100, 100, 40, 20},
+		{"c1", "c4", 100, 100, 30, 10},
+	}
+	tests := []struct {
+		name     string
+		minLinks int
+		minRatio float64
+		topN     int
+		expected map[string]string
+	}{
+		{"none", 0, 0, 0, map[string]string{}},
+		{"minLinks", 10, 0, 0, map[string]string{"c1-c2": "weak(links=5<10)"}},
+		{"minRatio", 0, 1, 0, map[string]string{
+			"c1-c2": "weak(ratio=0.5<1)", "c1-c3": "weak(ratio=0.5<1)"}},
+		// Ties are ranked in the order of the pairs
+		{"topN", 0, 0, 1, map[string]string{
+			"c1-c2": "weak(rank=3,2>1)", "c1-c4": "weak(rank=2,2>1)"}},
+		// Pairs pruned by links are not ranked
+		{"minLinks+topN", 10, 0, 1, map[string]string{
+			"c1-c2": "weak(links=5<10)", "c1-c4": "weak(rank=2,2>1)"}},
+	}
+	for _, tt := range tests {
+		p := setupPruner(t, groups, edges)
+		p.MinLinks, p.MinRatio, p.TopN = tt.minLinks, tt.minRatio, tt.topN
+		p.Run()
+		labels := prunedLabels(t, p)
+		for _, e := range edges {
+			pair := e.a + "-" + e.b
+			expected, ok := tt.expected[pair]
+			if !ok {
+				expected = "ok"
+			}
+			if labels[pair] != expected {
+				t.Errorf("%s: expected %s to be labeled %s, got %s", tt.name, pair, expected, labels[pair])
+			}
+		}
+	}
+}
+
+func TestPrunedClm(t *testing.T) {
+	edges := []pruneEdge{
+		{"c1", "c2", 100, 100, 5, 10},
+		{"c1", "c3", 100, 100, 50, 100},
+	}
+	p := setupPruner(t, [][]string{{"x1", "x2"}}, edges)
+	p.ClmFile = filepath.Join(filepath.Dir(p.PairsFile), "test.clm")
+	clm := ""
+	for _, e := range edges {
+		for _, o := range []string{"++", "+-", "-+", "--"} {
+			clm += fmt.Sprintf("%s%c %s%c\t%d\t%s\n", e.a, o[0], e.b, o[1], e.links,
+				strings.TrimSpace(strings.Repeat("1000 ", e.links)))
+		}
+	}
+	if err := ioutil.WriteFile(p.ClmFile, []byte(clm), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.MinLinks = 10
+	p.Run()
+
+	data, err := ioutil.ReadFile(allhic.RemoveExt(p.ClmFile) + ".prune.clm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(rows) != 4 {
+		t.Fatalf("Expected 4 clm lines after pruning, got %d", len(rows))
+	}
+	for _, row := range rows {
+		if !strings.HasPrefix(row, "c1") || !strings.Contains(strings.Fields(row)[1], "c3") {
+			t.Errorf("Expected only the lines of c1-c3 to be kept, got %s", row)
+		}
+	}
+}
+
+func TestPruneReport(t *testing.T) {
+	// The matching between the two groups is a near-tie, and a3 is only
+	// linked to its allele
+	edges := []pruneEdge{
+		{"a1", "a3", 100, 100, 50, 10},
+		{"a1", "b1", 100, 100, 100, 10},
+		{"a2", "b2", 100, 100, 100, 10},
+		{"a1", "b2", 100, 100, 99, 10},
+		{"a2", "b1", 100, 100, 99, 10},
+	}
+	p := setupPruner(t, [][]string{{"a1", "a2", "a3"}, {"b1", "b2"}}, edges)
+	p.Normalize = allhic.NormalizeNone
+	p.Report = true
+	p.Run()
+
+	data, err := ioutil.ReadFile(allhic.RemoveExt(p.PairsFile) + ".prune.report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report allhic.PruneReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Groups) != 2 || len(report.Groups[0].Edges) != 1 ||
+		report.Groups[0].Edges[0].Label != "allelic" {
+		t.Errorf("Expected the allelic pair a1-a3 in the first group, got %+v", report.Groups)
+	}
+	if len(report.Matchings) != 1 {
+		t.Fatalf("Expected 1 matching, got %d", len(report.Matchings))
+	}
+	matching := report.Matchings[0]
+	if matching.Total != 200 || matching.RunnerUp != 198 || !matching.Ambiguous {
+		t.Errorf("Expected an ambiguous matching of 200 vs 198, got %.1f vs %.1f (ambiguous: %v)",
+			matching.Total, matching.RunnerUp, matching.Ambiguous)
+	}
+	nMatched := 0
+	for _, e := range matching.Edges {
+		if e.Matched != (e.Label == "ok") {
+			t.Errorf("Edge %s-%s matched: %v but labeled %s", e.A, e.B, e.Matched, e.Label)
+		}
+		if e.Matched {
+			nMatched++
+		}
+	}
+	if nMatched != 2 {
+		t.Errorf("Expected 2 matched edges, got %d", nMatched)
+	}
+	if len(report.AmbiguousGroups) != 1 || report.AmbiguousGroups[0] != [2]int{0, 1} {
+		t.Errorf("Expected groups 0 and 1 to be ambiguous, got %v", report.AmbiguousGroups)
+	}
+	if len(report.IsolatedContigs) != 1 || report.IsolatedContigs[0] != "a3" {
+		t.Errorf("Expected a3 to be isolated, got %v", report.IsolatedContigs)
+	}
+}
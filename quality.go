@@ -0,0 +1,180 @@
+/*
+ *  quality.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+)
+
+// ContigQuality stores how well a contig fits in its cluster
+type ContigQuality struct {
+	contigID    int
+	cID         int     // Cluster of the contig, -1 if not clustered
+	ownLinkage  float64 // Average linkage to the rest of its own cluster
+	competitor  int     // Cluster (other than its own) with the largest average linkage
+	compLinkage float64
+	silhouette  float64 // (own - competitor) / max(own, competitor)
+	status      string
+}
+
+// ClusterQuality summarizes the links within and out of a cluster
+type ClusterQuality struct {
+	nContigs    int
+	length      int
+	intraLinks  float64
+	interLinks  float64
+	silhouette  float64 // Mean silhouette of the contigs in the cluster
+	nShort      int
+	nRepetitive int
+	nRecovered  int
+}
+
+// contigStatus returns the status of a contig during partition
+func (r *Partitioner) contigStatus(i int) string {
+	contig := r.contigs[i]
+	if !contig.skip {
+		return "OK"
+	}
+	if r.recovered[i] {
+		return contig.skipReason + ",RECOVERED"
+	}
+	return contig.skipReason
+}
+
+// assessClusters computes the quality of each contig and each cluster from
+// the normalized link matrix. Links are averaged in both directions since the
+// matrix is no longer symmetric after skipRepeats().
+func (r *Partitioner) assessClusters() ([]ContigQuality, []ClusterQuality) {
+	contigToCluster := map[int]int{}
+	for cID, cl := range r.clusters {
+		for _, id := range cl {
+			contigToCluster[id] = cID
+		}
+	}
+
+	contigs := make([]ContigQuality, len(r.contigs))
+	clusters := make([]ClusterQuality, len(r.clusters))
+	for i := range r.contigs {
+		cID, ok := contigToCluster[i]
+		if !ok {
+			cID = -1
+		}
+		totalLinkageByCluster := map[int]float64{}
+		for j := range r.matrix[i] {
+			if cj, ok := contigToCluster[j]; ok && j != i {
+				totalLinkageByCluster[cj] += float64(r.matrix[i][j]+r.matrix[j][i]) / 2
+			}
+		}
+
+		q := ContigQuality{contigID: i, cID: cID, competitor: -1, status: r.contigStatus(i)}
+		for cj, total := range totalLinkageByCluster {
+			size := len(r.clusters[cj])
+			if cj == cID {
+				size--
+			}
+			if size <= 0 {
+				continue
+			}
+			avgLinkage := total / float64(size)
+			if cj == cID {
+				q.ownLinkage = avgLinkage
+			} else if avgLinkage > q.compLinkage || (avgLinkage == q.compLinkage && cj < q.competitor) {
+				q.competitor, q.compLinkage = cj, avgLinkage
+			}
+		}
+		if best := math.Max(q.ownLinkage, q.compLinkage); best > 0 {
+			q.silhouette = (q.ownLinkage - q.compLinkage) / best
+		}
+		contigs[i] = q
+
+		if cID == -1 {
+			continue
+		}
+		c := &clusters[cID]
+		c.nContigs++
+		c.length += r.contigs[i].length
+		c.silhouette += q.silhouette
+		for cj, total := range totalLinkageByCluster {
+			if cj == cID {
+				c.intraLinks += total / 2 // Each pair is visited twice
+			} else {
+				c.interLinks += total
+			}
+		}
+		switch r.contigs[i].skipReason {
+		case "SHORT":
+			c.nShort++
+		case "REPETITIVE":
+			c.nRepetitive++
+		}
+		if r.recovered[i] {
+			c.nRecovered++
+		}
+	}
+	for cID := range clusters {
+		if clusters[cID].nContigs > 0 {
+			clusters[cID].silhouette /= float64(clusters[cID].nContigs)
+		}
+	}
+	return contigs, clusters
+}
+
+// writeQualityReport writes the per-cluster and per-contig quality next to
+// clusters.txt, so that contigs that are weakly attached to their clusters,
+// or attached to two clusters, can be inspected
+func (r *Partitioner) writeQualityReport() {
+	contigs, clusters := r.assessClusters()
+	prefix := RemoveExt(RemoveExt(r.PairsFile))
+
+	clusterfile := prefix + ".clusters.quality.txt"
+	f, err := os.Create(clusterfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, ClusterQualityHeader)
+	for cID, c := range clusters {
+		ratio := 0.0
+		if c.intraLinks+c.interLinks > 0 {
+			ratio = c.intraLinks / (c.intraLinks + c.interLinks)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.0f\t%.0f\t%.4f\t%.4f\t%d\t%d\t%d\n",
+			r.clusterLabel(cID), c.nContigs, c.length, c.intraLinks, c.interLinks,
+			ratio, c.silhouette, c.nShort, c.nRepetitive, c.nRecovered)
+	}
+	w.Flush()
+	f.Close()
+
+	contigfile := prefix + ".contigs.quality.txt"
+	f, err = os.Create(contigfile)
+	ErrorAbort(err)
+	w = bufio.NewWriter(f)
+	fmt.Fprintf(w, ContigQualityHeader)
+	nWeak := 0
+	for _, q := range contigs {
+		cluster, competitor := "-", "-"
+		if q.cID != -1 {
+			cluster = r.clusterLabel(q.cID)
+			if q.silhouette < 0 {
+				nWeak++
+			}
+		}
+		if q.competitor != -1 {
+			competitor = r.clusterLabel(q.competitor)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.1f\t%s\t%.1f\t%.4f\t%s\n",
+			r.contigs[q.contigID].name, cluster, q.ownLinkage, competitor, q.compLinkage,
+			q.silhouette, q.status)
+	}
+	w.Flush()
+	f.Close()
+	log.Noticef("Cluster quality written to `%s` and `%s` (%d clustered contigs with negative silhouette)",
+		clusterfile, contigfile, nWeak)
+}
@@ -0,0 +1,53 @@
+/*
+ *  quality_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAssessClusters(t *testing.T) {
+	// Contig 2 is linked more to the other cluster than to its own, and
+	// contig 5 is skipped as SHORT
+	links := map[[2]int]int64{{0, 1}: 10, {0, 2}: 10, {1, 2}: 10, {3, 4}: 20, {2, 3}: 40}
+	r := newTestPartitioner(links, 6)
+	r.contigs[5].skip, r.contigs[5].skipReason = true, "SHORT"
+	r.clusters = Clusters{0: {0, 1, 2}, 1: {3, 4}}
+	contigs, clusters := r.assessClusters()
+
+	expectedContigs := []ContigQuality{
+		{contigID: 0, cID: 0, ownLinkage: 10, competitor: -1, silhouette: 1, status: "OK"},
+		{contigID: 1, cID: 0, ownLinkage: 10, competitor: -1, silhouette: 1, status: "OK"},
+		{contigID: 2, cID: 0, ownLinkage: 10, competitor: 1, compLinkage: 20, silhouette: -.5, status: "OK"},
+		{contigID: 3, cID: 1, ownLinkage: 20, competitor: 0, compLinkage: 40. / 3, silhouette: 1. / 3, status: "OK"},
+		{contigID: 4, cID: 1, ownLinkage: 20, competitor: -1, silhouette: 1, status: "OK"},
+		{contigID: 5, cID: -1, competitor: -1, status: "SHORT"},
+	}
+	for i, q := range contigs {
+		e := expectedContigs[i]
+		if q.cID != e.cID || q.competitor != e.competitor || q.status != e.status ||
+			math.Abs(q.ownLinkage-e.ownLinkage) > 1e-9 || math.Abs(q.compLinkage-e.compLinkage) > 1e-9 ||
+			math.Abs(q.silhouette-e.silhouette) > 1e-9 {
+			t.Errorf("Expected quality of contig %d to be %+v, got %+v", i, e, q)
+		}
+	}
+
+	expectedClusters := []ClusterQuality{
+		{nContigs: 3, length: 300000, intraLinks: 30, interLinks: 40, silhouette: 0.5},
+		{nContigs: 2, length: 200000, intraLinks: 20, interLinks: 40, silhouette: 2. / 3},
+	}
+	for cID, c := range clusters {
+		e := expectedClusters[cID]
+		if c.nContigs != e.nContigs || c.length != e.length || c.intraLinks != e.intraLinks ||
+			c.interLinks != e.interLinks || math.Abs(c.silhouette-e.silhouette) > 1e-9 {
+			t.Errorf("Expected quality of cluster %d to be %+v, got %+v", cID, e, c)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ *  rescue.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Rescuer assigns the contigs that are left out of clusters.txt to the groups
+// they are most strongly linked to
+type Rescuer struct {
+	Contigsfile  string
+	PairsFile    string
+	ClustersFile string
+	// Parameters
+	Ratio    float64 // Min ratio of the best vs the second best average linkage
+	MinLinks int     // Min number of Hi-C links to the best group
+	// Output files
+	OutREfiles []string
+}
+
+// RescueResult records the decision on one unassigned contig
+type RescueResult struct {
+	contigID    int
+	cID         int // Best linked group, -1 if not linked to any group
+	links       int // Number of Hi-C links to the best group
+	avgLinkage  float64
+	competitor  int
+	compLinkage float64
+	status      string
+}
+
+// Run is the main function body of rescue
+func (r *Rescuer) Run() {
+	p := &Partitioner{Contigsfile: r.Contigsfile, PairsFile: r.PairsFile}
+	p.readRE()
+	p.makeMatrix()
+	p.readClusters(r.ClustersFile)
+
+	results := r.rescue(p)
+	r.writeReport(p, results)
+	for _, cl := range p.clusters {
+		sort.Ints(cl) // Same order as in the RE counts file
+	}
+	p.splitRE()
+	r.OutREfiles = p.OutREfiles
+	log.Notice("Success")
+}
+
+// rawLinks counts the number of Hi-C links between contigs, in both directions
+func (r *Rescuer) rawLinks(p *Partitioner) LinkMatrix {
+	M := NewLinkMatrix(len(p.contigs))
+	for _, e := range parseDist(r.PairsFile) {
+		a, aok := p.contigToIdx[e.at]
+		b, bok := p.contigToIdx[e.bt]
+		if !aok || !bok || a == b {
+			continue
+		}
+		M[a][b] += int64(e.nObservedLinks)
+		M[b][a] += int64(e.nObservedLinks)
+	}
+	return M
+}
+
+// rescue places each unassigned contig to the group with the largest average
+// linkage, if the contig has at least MinLinks links to that group and the
+// average linkage is at least Ratio times that of the second best group. The
+// decisions are made against the original groups, so the order of the contigs
+// does not matter.
+func (r *Rescuer) rescue(p *Partitioner) []*RescueResult {
+	contigToCluster := map[int]int{}
+	for cID, cl := range p.clusters {
+		for _, id := range cl {
+			contigToCluster[id] = cID
+		}
+	}
+	raw := r.rawLinks(p)
+
+	results := []*RescueResult{}
+	counts := map[string]int{}
+	for i := range p.contigs {
+		if _, ok := contigToCluster[i]; ok {
+			continue
+		}
+		res := &RescueResult{contigID: i, cID: -1, competitor: -1}
+		results = append(results, res)
+
+		linkages := p.findClusterLinkage(i, contigToCluster)
+		sort.Slice(linkages, func(a, b int) bool {
+			if linkages[a].avgLinkage == linkages[b].avgLinkage {
+				return linkages[a].cID < linkages[b].cID
+			}
+			return linkages[a].avgLinkage > linkages[b].avgLinkage
+		})
+		if len(linkages) == 0 {
+			res.status = "NO_LINKS"
+			counts[res.status]++
+			continue
+		}
+		res.cID, res.avgLinkage = linkages[0].cID, linkages[0].avgLinkage
+		if len(linkages) > 1 {
+			res.competitor, res.compLinkage = linkages[1].cID, linkages[1].avgLinkage
+		}
+		for id, w := range raw[i] {
+			if cID, ok := contigToCluster[id]; ok && cID == res.cID {
+				res.links += int(w)
+			}
+		}
+
+		switch {
+		case res.links < r.MinLinks:
+			res.status = "FEW_LINKS"
+		case res.compLinkage > 0 && res.avgLinkage < r.Ratio*res.compLinkage:
+			res.status = "LOW_RATIO"
+		default:
+			res.status = "RESCUED"
+		}
+		counts[res.status]++
+	}
+
+	for _, res := range results {
+		if res.status == "RESCUED" {
+			p.clusters[res.cID] = append(p.clusters[res.cID], res.contigID)
+		}
+	}
+	log.Noticef("Rescued %d of %d unassigned contigs (Ratio = %g, MinLinks = %d): %d with too few links, %d with low ratio, %d not linked",
+		counts["RESCUED"], len(results), r.Ratio, r.MinLinks,
+		counts["FEW_LINKS"], counts["LOW_RATIO"], counts["NO_LINKS"])
+	return results
+}
+
+// writeReport writes the decisions on all unassigned contigs, with the best
+// group, the competing group and the average linkages to both
+func (r *Rescuer) writeReport(p *Partitioner, results []*RescueResult) {
+	outfile := RemoveExt(r.ClustersFile) + ".rescue.txt"
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	fmt.Fprintf(w, RescueHeader)
+	for _, res := range results {
+		group, competitor := "-", "-"
+		if res.cID != -1 {
+			group = p.clusterLabel(res.cID)
+		}
+		if res.competitor != -1 {
+			competitor = p.clusterLabel(res.competitor)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%.1f\t%s\t%.1f\t%s\n",
+			p.contigs[res.contigID].name, p.contigs[res.contigID].length,
+			group, res.links, res.avgLinkage, competitor, res.compLinkage, res.status)
+	}
+	w.Flush()
+	log.Noticef("Rescue report written to `%s`", outfile)
+}
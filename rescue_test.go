@@ -0,0 +1,70 @@
+/*
+ *  rescue_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tanghaibao/allhic"
+)
+
+func TestRescue(t *testing.T) {
+	contigs := []string{"g1a", "g1b", "g2a", "g2b", "u1", "u2", "u3", "u4"}
+	links := map[string]int{
+		"g1a-g1b": 50, "g2a-g2b": 50,
+		// u1 is rescued, u2 is linked to both groups, u3 has too few links
+		"g1a-u1": 20, "g1b-u1": 20, "g2a-u1": 2,
+		"g1a-u2": 10, "g2a-u2": 8,
+		"g1a-u3": 3,
+	}
+	dir := t.TempDir()
+	countsFile := filepath.Join(dir, "test.counts_GATC.txt")
+	pairsFile := filepath.Join(dir, "test.pairs.txt")
+	clustersFile := filepath.Join(dir, "test.clusters.txt")
+	writePartitionFiles(t, countsFile, pairsFile, contigs, func(a, b string) int {
+		return links[a+"-"+b]
+	})
+	clusters := "#Group\tnContigs\tContigs\n2g1\t2\tg1a g1b\n2g2\t2\tg2a g2b\n"
+	if err := ioutil.WriteFile(clustersFile, []byte(clusters), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := allhic.Rescuer{Contigsfile: countsFile, PairsFile: pairsFile, ClustersFile: clustersFile,
+		Ratio: 3, MinLinks: 10}
+	r.Run()
+	expected := map[string]string{"u1": "2g1 40 RESCUED", "u2": "2g1 10 LOW_RATIO",
+		"u3": "2g1 3 FEW_LINKS", "u4": "- 0 NO_LINKS"}
+	rows := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dir, "test.clusters.rescue.txt"))), "\n")[1:]
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d unassigned contigs, got %d", len(expected), len(rows))
+	}
+	for _, row := range rows {
+		words := strings.Split(row, "\t")
+		if got := strings.Join([]string{words[2], words[3], words[7]}, " "); got != expected[words[0]] {
+			t.Errorf("Expected %s to be %s, got %s", words[0], expected[words[0]], got)
+		}
+	}
+
+	// Only u1 is added to the counts file of the group
+	if len(r.OutREfiles) != 2 {
+		t.Fatalf("Expected 2 counts files, got %v", r.OutREfiles)
+	}
+	data := readFile(t, r.OutREfiles[0])
+	for _, ctg := range []string{"g1a", "g1b", "u1"} {
+		if !strings.Contains(data, ctg+"\t") {
+			t.Errorf("Expected %s in `%s`", ctg, r.OutREfiles[0])
+		}
+	}
+	if strings.Contains(data, "u2\t") || strings.Contains(data, "u3\t") {
+		t.Errorf("Expected only u1 rescued into `%s`, got\n%s", r.OutREfiles[0], data)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ *  search.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// SARun optimizes the ordering by simulated annealing, with MutInversion and
+// MutInsertion as the moves. The initial temperature is set so that an average
+// uphill move is accepted with probability SAInitialAcceptance, and the
+// temperature is lowered by SACoolingRate after every SAMovesPerTig moves per
+// contig, until it drops below SAMinTempRatio of the initial temperature or
+// no moves are accepted at a temperature.
+func (r *CLM) SARun(fwtour *os.File, opt *Optimizer, phase int) Tour {
+	n := r.Tour.Len()
+	if n < 2 {
+		return r.Tour
+	}
+	rng := opt.rng
+	e := NewTourEvaluator(r.Tour.Clone().(Tour))
+	move := func() TourMove {
+		p, q := randomTwoInts(e.Tour, rng)
+		if rng.Float64() < .5 {
+			return InversionMove(p, q)
+		}
+		if rng.Float64() < .5 {
+			return InsertionMove(q, 1, p)
+		}
+		return InsertionMove(p, 1, q)
+	}
+
+	// Sample the uphill moves to set the initial temperature
+	uphill, nUphill := 0.0, 0
+	for i := 0; i < SASampleMoves; i++ {
+		if delta := e.Delta(move()); delta > 0 {
+			uphill += delta
+			nUphill++
+		}
+	}
+	t0 := math.Abs(e.Score) * 1e-9
+	if nUphill > 0 {
+		t0 = -uphill / float64(nUphill) / math.Log(SAInitialAcceptance)
+	}
+	log.Noticef("SA initialized (moves: %d per temperature, T0: %.3g, cooling: %.2f, rng: %d)",
+		SAMovesPerTig*n, t0, SACoolingRate, opt.Seed)
+
+	best, bestCost := e.Tour.Clone().(Tour), e.Score
+	level, updated := 0, 0
+	for temp := t0; temp > SAMinTempRatio*t0; temp *= SACoolingRate {
+		accepted := 0
+		for i := 0; i < SAMovesPerTig*n; i++ {
+			m := move()
+			delta := e.Delta(m)
+			if delta > 0 && rng.Float64() >= math.Exp(-delta/temp) {
+				continue
+			}
+			e.Apply(m, delta)
+			accepted++
+			if e.Score < bestCost {
+				best, bestCost = e.Tour.Clone().(Tour), e.Score
+				updated = level
+			}
+		}
+		// Avoid the drift from adding up the deltas
+		e.Score, _ = e.Tour.Evaluate()
+		level++
+		if level%SASnapshotLevels == 0 || accepted == 0 {
+			fmt.Printf("Current iteration SA%d-%d: max_score=%.5f\n", phase, level, -bestCost)
+			r.printTour(fwtour, best, fmt.Sprintf("SA%d-%d-%.5f", phase, level, -bestCost))
+		}
+		if accepted == 0 {
+			break
+		}
+	}
+	log.Noticef("SA%d stopped at temperature level %d, best score %.5f found at level %d",
+		phase, level, -bestCost, updated)
+
+	r.Tour = best
+	return r.Tour
+}
+
+// LocalSearch polishes the ordering with the deterministic 2-opt and Or-opt
+// moves. 2-opt reverses a stretch of contigs, and Or-opt moves up to
+// OrOptMaxSegment consecutive contigs to another position. Improving moves
+// are taken as soon as they are found, until a full pass finds none.
+func (r *CLM) LocalSearch(fwtour *os.File, phase int) Tour {
+	e := NewTourEvaluator(r.Tour.Clone().(Tour))
+	n := e.Tour.Len()
+	// accept applies the move if it improves the score by more than the
+	// rounding errors in the deltas
+	accept := func(m TourMove) bool {
+		delta := e.Delta(m)
+		if delta < -MinImprovement*math.Abs(e.Score) {
+			e.Apply(m, delta)
+			return true
+		}
+		return false
+	}
+
+	log.Noticef("Local search started with score %.5f", -e.Score)
+	pass := 0
+	for improved := true; improved; {
+		pass++
+		n2opt, nOrOpt := 0, 0
+		// 2-opt, reverse the contigs in [i, j]
+		for i := 0; i < n-1; i++ {
+			for j := i + 1; j < n; j++ {
+				if accept(InversionMove(i, j)) {
+					n2opt++
+				}
+			}
+		}
+		// Or-opt, move the k contigs in [i, i+k) to start at position p
+		for k := 1; k <= OrOptMaxSegment && k < n; k++ {
+			for i := 0; i+k <= n; i++ {
+				for p := 0; p <= n-k; p++ {
+					if p != i && accept(InsertionMove(i, k, p)) {
+						nOrOpt++
+						break
+					}
+				}
+			}
+		}
+		e.Score, _ = e.Tour.Evaluate()
+		improved = n2opt+nOrOpt > 0
+		fmt.Printf("Current iteration LS%d-%d: max_score=%.5f (2-opt: %d, Or-opt: %d)\n",
+			phase, pass, -e.Score, n2opt, nOrOpt)
+		r.printTour(fwtour, e.Tour, fmt.Sprintf("LS%d-%d-%.5f", phase, pass, -e.Score))
+	}
+	log.Noticef("Local search stopped after %d passes with score %.5f", pass, -e.Score)
+
+	r.Tour = e.Tour
+	return r.Tour
+}
@@ -0,0 +1,74 @@
+/*
+ *  search_test.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+// setupSearch takes 30 contigs from the simulated group in a random order, and
+// opens a tour file in a temp dir to log the search
+func setupSearch(t *testing.T, seed int64) (*CLM, *Optimizer, *os.File) {
+	opt := &Optimizer{Seed: seed, rng: rand.New(rand.NewSource(seed))}
+	clm := NewCLM(path.Join("tests", "simulation", "test.clm"), path.Join("tests", "simulation", "test.ids"))
+	clm.Activate(true, opt.rng)
+	clm.Tour.Tigs = clm.Tour.Tigs[:30]
+	fwtour, err := os.Create(filepath.Join(t.TempDir(), "test.tour"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fwtour.Close() })
+	return clm, opt, fwtour
+}
+
+func TestSearchNoWorse(t *testing.T) {
+	for seed := int64(0); seed < 3; seed++ {
+		clm, opt, fwtour := setupSearch(t, seed)
+		before, _ := clm.Tour.Evaluate()
+		after, _ := clm.LocalSearch(fwtour, 1).Evaluate()
+		if after > before {
+			t.Errorf("Seed %d: local search made the score worse, %.5f to %.5f", seed, -before, -after)
+		}
+		// SA starts from the local optimum, and keeps it unless a better tour
+		// is found
+		before = after
+		after, _ = clm.SARun(fwtour, opt, 1).Evaluate()
+		if after > before {
+			t.Errorf("Seed %d: SA made the score worse, %.5f to %.5f", seed, -before, -after)
+		}
+	}
+}
+
+func TestLocalSearchFixedPoint(t *testing.T) {
+	clm, _, fwtour := setupSearch(t, 42)
+	e := NewTourEvaluator(clm.LocalSearch(fwtour, 1))
+	n := e.Tour.Len()
+	minDelta := -MinImprovement * math.Abs(e.Score)
+	for i := 0; i < n-1; i++ {
+		for j := i + 1; j < n; j++ {
+			if delta := e.Delta(InversionMove(i, j)); delta < minDelta {
+				t.Errorf("2-opt %d, %d still improves the score by %g", i, j, -delta)
+			}
+		}
+	}
+	for k := 1; k <= OrOptMaxSegment; k++ {
+		for i := 0; i+k <= n; i++ {
+			for p := 0; p <= n-k; p++ {
+				if delta := e.Delta(InsertionMove(i, k, p)); p != i && delta < minDelta {
+					t.Errorf("Or-opt %d, %d, %d still improves the score by %g", i, k, p, -delta)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+/*
+ *  synteny.go
+ *  allhic
+ *
+ *  Created by Haibao Tang on 10/18/26
+ *  Copyright © 2026 Haibao Tang. All rights reserved.
+ */
+
+package allhic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeneRecord stores the location of a gene from the BED file, along with its
+// rank along the sequence, which is used to measure distances in gene units
+type GeneRecord struct {
+	BedLine
+	rank int
+}
+
+// Anchor is a homologous gene pair between a contig gene and a reference gene
+type Anchor struct {
+	a     *GeneRecord // Gene on the contig
+	b     *GeneRecord // Gene on the reference
+	score float64
+}
+
+// SyntenyBlock is a chain of collinear anchors between a contig and a reference
+// chromosome
+type SyntenyBlock struct {
+	Contig  string
+	Chrom   string
+	Anchors []*Anchor
+}
+
+// AlleleRow is one line in the alleles.table, i.e. all the contigs that are
+// syntenic to the same reference gene
+type AlleleRow struct {
+	Chrom   string
+	Pos     int
+	Contigs []string
+}
+
+// String outputs the string representation of AlleleRow
+func (r AlleleRow) String() string {
+	return fmt.Sprintf("%s\t%d\t%s", r.Chrom, r.Pos, strings.Join(r.Contigs, "\t"))
+}
+
+// parseBedFile imports all genes from a bedfile, and assign the ranks based
+// on the order of the genes on each sequence
+func parseBedFile(bedfile string) map[string]*GeneRecord {
+	fh := mustOpen(bedfile)
+	defer fh.Close()
+	log.Noticef("Parse bedfile `%s`", bedfile)
+	reader := bufio.NewReader(fh)
+
+	genes := []*GeneRecord{}
+	for {
+		row, err := reader.ReadString('\n')
+		row = strings.TrimSpace(row)
+		if row == "" && err == io.EOF {
+			break
+		}
+		if row == "" || row[0] == '#' {
+			continue
+		}
+		words := strings.Split(row, "\t")
+		if len(words) < 4 {
+			log.Fatalf("Malformed line: %s, expecting at least 4 columns", row)
+		}
+		start, _ := strconv.Atoi(words[1])
+		end, _ := strconv.Atoi(words[2])
+		genes = append(genes, &GeneRecord{
+			BedLine: BedLine{
+				seqid: words[0],
+				start: start,
+				end:   end,
+				name:  words[3],
+				size:  end - start,
+			},
+		})
+	}
+
+	sort.SliceStable(genes, func(i, j int) bool {
+		return genes[i].seqid < genes[j].seqid ||
+			(genes[i].seqid == genes[j].seqid && genes[i].start < genes[j].start)
+	})
+	nameToGene := map[string]*GeneRecord{}
+	for i, gene := range genes {
+		if i > 0 && genes[i-1].seqid == gene.seqid {
+			gene.rank = genes[i-1].rank + 1
+		}
+		nameToGene[gene.name] = gene
+	}
+	log.Noticef("A total of %d genes imported", len(nameToGene))
+	return nameToGene
+}
+
+// parseAnchorsFile imports the gene pairs from the tabular BLAST or LAST output.
+// The first two columns are the contig gene and the reference gene, and the last
+// column is taken as the score, for example:
+// gene00000	gene00000	100	0	0	0	0	0	0	0	0	100
+func (r *Alleler) parseAnchorsFile(contigGenes, refGenes map[string]*GeneRecord) []*Anchor {
+	fh := mustOpen(r.AnchorsFile)
+	defer fh.Close()
+	log.Noticef("Parse anchors file `%s`", r.AnchorsFile)
+	reader := bufio.NewReader(fh)
+
+	// Only keep the best hit for each gene pair
+	bestAnchors := map[[2]string]*Anchor{}
+	// Best scores for each gene, which are used to compute C-scores
+	bestScoresA := map[string]float64{}
+	bestScoresB := map[string]float64{}
+	for {
+		row, err := reader.ReadString('\n')
+		row = strings.TrimSpace(row)
+		if row == "" && err == io.EOF {
+			break
+		}
+		if row == "" || row[0] == '#' {
+			continue
+		}
+		words := strings.Fields(row)
+		if len(words) < 2 {
+			continue
+		}
+		a, aok := contigGenes[words[0]]
+		b, bok := refGenes[words[1]]
+		if !aok || !bok {
+			continue
+		}
+		score := 1.0
+		if len(words) > 2 {
+			score, _ = strconv.ParseFloat(words[len(words)-1], 64)
+		}
+		pair := [2]string{a.name, b.name}
+		if anchor, ok := bestAnchors[pair]; !ok || anchor.score < score {
+			bestAnchors[pair] = &Anchor{a: a, b: b, score: score}
+		}
+		if score > bestScoresA[a.name] {
+			bestScoresA[a.name] = score
+		}
+		if score > bestScoresB[b.name] {
+			bestScoresB[b.name] = score
+		}
+	}
+
+	// C-score filtering, similar to MCscan. C-score is the ratio of the score
+	// against the best score of either gene.
+	anchors := []*Anchor{}
+	for _, anchor := range bestAnchors {
+		best := bestScoresA[anchor.a.name]
+		if bs := bestScoresB[anchor.b.name]; bs > best {
+			best = bs
+		}
+		if best > 0 && anchor.score/best < r.MinCscore {
+			continue
+		}
+		anchors = append(anchors, anchor)
+	}
+	log.Noticef("Imported %d anchors (%d passed C-score >= %.2f)",
+		len(bestAnchors), len(anchors), r.MinCscore)
+	return anchors
+}
+
+// findSyntenyBlocks chains the anchors into collinear blocks. Two anchors are
+// chained when they are within MaxGap genes on both the contig and the
+// reference. Blocks smaller than MinBlockSize anchors are discarded.
+func (r *Alleler) findSyntenyBlocks(anchors []*Anchor) []SyntenyBlock {
+	// Group the anchors by the pair of sequences
+	groups := map[[2]string][]*Anchor{}
+	for _, anchor := range anchors {
+		key := [2]string{anchor.a.seqid, anchor.b.seqid}
+		groups[key] = append(groups[key], anchor)
+	}
+	keys := make([][2]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+
+	blocks := []SyntenyBlock{}
+	for _, key := range keys {
+		group := groups[key]
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].a.rank < group[j].a.rank ||
+				(group[i].a.rank == group[j].a.rank && group[i].b.rank < group[j].b.rank)
+		})
+
+		// Single-linkage clustering of the anchors
+		parent := make([]int, len(group))
+		for i := range parent {
+			parent[i] = i
+		}
+		var find func(int) int
+		find = func(i int) int {
+			if parent[i] != i {
+				parent[i] = find(parent[i])
+			}
+			return parent[i]
+		}
+		for i := 0; i < len(group); i++ {
+			for j := i - 1; j >= 0 && group[i].a.rank-group[j].a.rank <= r.MaxGap; j-- {
+				if abs(group[i].b.rank-group[j].b.rank) <= r.MaxGap {
+					parent[find(i)] = find(j)
+				}
+			}
+		}
+
+		chains := map[int][]*Anchor{}
+		roots := []int{}
+		for i, anchor := range group {
+			root := find(i)
+			if _, ok := chains[root]; !ok {
+				roots = append(roots, root)
+			}
+			chains[root] = append(chains[root], anchor)
+		}
+		for _, root := range roots {
+			chain := chains[root]
+			if len(chain) < r.MinBlockSize {
+				continue
+			}
+			blocks = append(blocks, SyntenyBlock{
+				Contig:  key[0],
+				Chrom:   key[1],
+				Anchors: chain,
+			})
+		}
+	}
+	log.Noticef("Found %d synteny blocks (MinBlockSize = %d, MaxGap = %d)",
+		len(blocks), r.MinBlockSize, r.MaxGap)
+	return blocks
+}
+
+// groupAllelesBySynteny collects contigs that hit the same reference gene in
+// the synteny blocks. Only genes with at least two contigs are reported.
+func groupAllelesBySynteny(blocks []SyntenyBlock) []AlleleRow {
+	refGeneToContigs := map[*GeneRecord]map[string]bool{}
+	for _, block := range blocks {
+		for _, anchor := range block.Anchors {
+			if _, ok := refGeneToContigs[anchor.b]; !ok {
+				refGeneToContigs[anchor.b] = map[string]bool{}
+			}
+			refGeneToContigs[anchor.b][block.Contig] = true
+		}
+	}
+
+	rows := []AlleleRow{}
+	for gene, contigSet := range refGeneToContigs {
+		if len(contigSet) < 2 {
+			continue
+		}
+		contigs := make([]string, 0, len(contigSet))
+		for contig := range contigSet {
+			contigs = append(contigs, contig)
+		}
+		sort.Strings(contigs)
+		rows = append(rows, AlleleRow{Chrom: gene.seqid, Pos: gene.start, Contigs: contigs})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Chrom < rows[j].Chrom ||
+			(rows[i].Chrom == rows[j].Chrom && rows[i].Pos < rows[j].Pos)
+	})
+	return rows
+}
+
+// writeAllelesTable writes the allele groups in the format that can be read
+// by parseAllelesTable()
+func writeAllelesTable(outfile string, rows []AlleleRow) {
+	f, err := os.Create(outfile)
+	ErrorAbort(err)
+	w := bufio.NewWriter(f)
+	defer f.Close()
+
+	for _, row := range rows {
+		fmt.Fprintln(w, row)
+	}
+	w.Flush()
+	log.Noticef("A total of %d allele groups written to `%s`", len(rows), outfile)
+}
+
+// runSynteny builds the allele table from the gene synteny between the contigs
+// and a closely-related reference genome
+func (r *Alleler) runSynteny() {
+	contigGenes := parseBedFile(r.ContigBedFile)
+	refGenes := parseBedFile(r.RefBedFile)
+	anchors := r.parseAnchorsFile(contigGenes, refGenes)
+	r.Blocks = r.findSyntenyBlocks(anchors)
+	r.Alleles = groupAllelesBySynteny(r.Blocks)
+	if r.OutAllelesFile == "" {
+		r.OutAllelesFile = RemoveExt(r.AnchorsFile) + ".alleles.table"
+	}
+	writeAllelesTable(r.OutAllelesFile, r.Alleles)
+}